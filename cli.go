@@ -1,19 +1,27 @@
 package main // import "github.com/justone/dockviz"
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 )
 
 type GlobalOptions struct {
-	TLSCaCert string `long:"tlscacert" value-name:"~/.docker/ca.pem" description:"Trust certs signed only by this CA"`
-	TLSCert   string `long:"tlscert" value-name:"~/.docker/cert.pem" description:"Path to TLS certificate file"`
-	TLSKey    string `long:"tlskey" value-name:"~/.docker/key.pem" description:"Path to TLS key file"`
-	TLSVerify bool   `long:"tlsverify" description:"Use TLS and verify the remote"`
-	Host      string `long:"host" short:"H" value-name:"unix:///var/run/docker.sock" description:"Docker host to connect to"`
-	Version   func() `long:"version" short:"v" description:"Display version information."`
+	TLSCaCert  string        `long:"tlscacert" value-name:"~/.docker/ca.pem" description:"Trust certs signed only by this CA"`
+	TLSCert    string        `long:"tlscert" value-name:"~/.docker/cert.pem" description:"Path to TLS certificate file"`
+	TLSKey     string        `long:"tlskey" value-name:"~/.docker/key.pem" description:"Path to TLS key file"`
+	TLSVerify  bool          `long:"tlsverify" description:"Use TLS and verify the remote"`
+	Host       string        `long:"host" short:"H" value-name:"unix:///var/run/docker.sock" description:"Docker host to connect to"`
+	Retries    int           `long:"retries" default:"0" description:"Retry connecting to the daemon, and listing its images, this many times (listing retries dropped connections and daemon failures twice regardless)"`
+	RetryDelay time.Duration `long:"retry-delay" default:"500ms" description:"Delay before the first retry, doubled after each one"`
+	Timeout    time.Duration `long:"call-timeout" value-name:"duration" description:"Give up on each per-image call to the daemon (e.g. fetching a history) after this long"`
+	Verbose    bool          `long:"verbose" description:"Show details about the connection to the daemon"`
+	Strict     bool          `long:"strict" description:"Fail instead of warning about inconsistent data"`
+	Quiet      bool          `long:"quiet" short:"q" description:"Don't show progress while waiting on the daemon"`
+	Version    func()        `long:"version" short:"v" description:"Display version information."`
 }
 
 var globalOptions GlobalOptions
@@ -21,12 +29,18 @@ var parser = flags.NewParser(&globalOptions, flags.Default)
 
 var version = "v0.3"
 
+// exit status when --strict is set and there are no images to display
+const exitNoImages = 3
+
 func main() {
 	globalOptions.Version = func() {
 		fmt.Println("dockviz", version)
 		os.Exit(0)
 	}
 	if _, err := parser.Parse(); err != nil {
+		if errors.Is(err, errNoImages) {
+			os.Exit(exitNoImages)
+		}
 		os.Exit(1)
 	}
 }
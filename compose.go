@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+type composeFile struct {
+	Services map[string]struct {
+		Image string `yaml:"image"`
+	} `yaml:"services"`
+}
+
+type composeService struct {
+	name  string
+	image string
+}
+
+// parseCompose lists the services of a compose file along with the image
+// each one uses, sorted by service name.  Services that are only built have
+// an empty image.
+func parseCompose(data []byte) ([]composeService, error) {
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, newKindError(ErrParse, "Unable to parse compose file: %w", err)
+	}
+
+	var services []composeService
+	for name, service := range compose.Services {
+		services = append(services, composeService{name: name, image: service.Image})
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].name < services[j].name
+	})
+
+	return services, nil
+}
+
+// composeImageIds resolves the images used by a compose file's services,
+// noting the services that have no image or whose image is not present.
+func composeImageIds(path string, images []Image, notes io.Writer) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	services, err := parseCompose(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, service := range services {
+		if len(service.image) == 0 {
+			fmt.Fprintf(notes, "Note: service %s has no image, skipping\n", service.name)
+			continue
+		}
+		image, err := findStartImage(service.image, &images)
+		if err != nil {
+			fmt.Fprintf(notes, "Note: image %s of service %s was not found, skipping\n", service.image, service.name)
+			continue
+		}
+		ids = append(ids, image.Id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FromCompose(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+
+	var notes bytes.Buffer
+	ids, err := composeImageIds(filepath.Join("testdata", "docker-compose.yml"), *im, &notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes.String() != "Note: service worker has no image, skipping\n" {
+		t.Fatalf("unexpected notes '%s'", notes.String())
+	}
+	if len(ids) != 2 || truncate(ids[0]) != "574c5faaf8d4" || truncate(ids[1]) != "c87be8e5e697" {
+		t.Fatalf("unexpected images %v", ids)
+	}
+
+	selected := selectImages(*im, ids, nil)
+	var got []string
+	for _, image := range selected {
+		got = append(got, truncate(image.Id))
+	}
+	expected := []string{"c87be8e5e697", "626147582d2a", "574c5faaf8d4", "735f5db56261", "4c1208b690c6"}
+	if len(got) != len(expected) {
+		t.Fatalf("selected %v, expected %v", got, expected)
+	}
+	for index := range expected {
+		if got[index] != expected[index] {
+			t.Fatalf("selected %v, expected %v", got, expected)
+		}
+	}
+
+	if _, err := parseCompose([]byte("services: [")); err == nil {
+		t.Fatal("invalid compose file did not cause an error")
+	}
+}
+
+func Test_FromComposeAllMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	ioutil.WriteFile(path, []byte("services:\n  web:\n    image: gone:1.0\n  db:\n    image: missing\n"), 0644)
+
+	written, err := executeImages(t, ImagesCommand{FromCompose: path, Count: true, TruncLength: 12}, treeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != "0\n" {
+		t.Fatalf("expected no images when no service image is present, got '%s'", written)
+	}
+}
@@ -6,24 +6,31 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 )
 
 type Container struct {
 	Id      string
 	Image   string
+	ImageID string
 	Names   []string
 	Ports   []map[string]interface{}
 	Created int64
 	Status  string
 	Command string
+	Labels  map[string]string
 }
 
 type ContainersCommand struct {
-	Dot        bool `short:"d" long:"dot" description:"Show container information as Graphviz dot."`
-	NoTruncate bool `short:"n" long:"no-trunc" description:"Don't truncate the container IDs."`
+	Dot         bool `short:"d" long:"dot" description:"Show container information as Graphviz dot."`
+	Tree        bool `short:"t" long:"tree" description:"Show containers as leaves of the image tree, under the image they run."`
+	Compose     bool `long:"compose" description:"Show containers as Graphviz dot, clustered by compose project with edges for service dependencies."`
+	NoTruncate  bool `short:"n" long:"no-trunc" description:"Don't truncate the container IDs."`
+	Concurrency int  `long:"concurrency" default:"8" description:"Number of containers to inspect at once for --compose."`
 }
 
 var containersCommand ContainersCommand
@@ -31,17 +38,18 @@ var containersCommand ContainersCommand
 func (x *ContainersCommand) Execute(args []string) error {
 
 	var containers *[]Container
+	var client *docker.Client
 
 	stat, err := os.Stdin.Stat()
 	if err != nil {
-		return fmt.Errorf("error reading stdin stat", err)
+		return fmt.Errorf("error reading stdin stat: %w", err)
 	}
 
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
 		// read in stdin
 		stdin, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
-			return fmt.Errorf("error reading all input", err)
+			return fmt.Errorf("error reading all input: %w", err)
 		}
 
 		containers, err = parseContainersJSON(stdin)
@@ -50,7 +58,7 @@ func (x *ContainersCommand) Execute(args []string) error {
 		}
 	} else {
 
-		client, err := connect()
+		client, err = connect()
 		if err != nil {
 			return err
 		}
@@ -58,9 +66,9 @@ func (x *ContainersCommand) Execute(args []string) error {
 		clientContainers, err := client.ListContainers(docker.ListContainersOptions{All: true})
 		if err != nil {
 			if in_docker := os.Getenv("IN_DOCKER"); len(in_docker) > 0 {
-				return fmt.Errorf("Unable to access Docker socket, please run like this:\n  docker run --rm -v /var/run/docker.sock:/var/run/docker.sock nate/dockviz containers <args>\nFor more help, run 'dockviz help'")
+				return newKindError(ErrNoDaemon, "Unable to access Docker socket (%w), please run like this:\n  docker run --rm -v /var/run/docker.sock:/var/run/docker.sock nate/dockviz containers <args>\nFor more help, run 'dockviz help'", err)
 			} else {
-				return fmt.Errorf("Unable to connect: %s\nFor help, run 'dockviz help'", err)
+				return newKindError(ErrNoDaemon, "Unable to connect: %w\nFor help, run 'dockviz help'", err)
 			}
 		}
 
@@ -69,21 +77,44 @@ func (x *ContainersCommand) Execute(args []string) error {
 			conts = append(conts, Container{
 				container.ID,
 				container.Image,
+				container.ImageID,
 				container.Names,
 				apiPortToMap(container.Ports),
 				container.Created,
 				container.Status,
 				container.Command,
+				container.Labels,
 			})
 		}
 
+		if containersCommand.Compose {
+			if err := inspectLabels(client, conts, containersCommand.Concurrency, os.Stderr, globalOptions.Strict); err != nil {
+				return err
+			}
+		}
+
 		containers = &conts
 	}
 
-	if containersCommand.Dot {
+	if containersCommand.Tree {
+		// the images always come from the daemon
+		if client == nil {
+			if client, err = connect(); err != nil {
+				return err
+			}
+		}
+		images, err := daemonImages(client, false, "containers")
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(containersTree(images, *containers, TreeOptions{NoTruncate: containersCommand.NoTruncate, SizeFormat: "si"}))
+	} else if containersCommand.Compose {
+		fmt.Print(composeContainersToDot(*containers))
+	} else if containersCommand.Dot {
 		fmt.Printf(jsonContainersToDot(containers))
 	} else {
-		return fmt.Errorf("Please specify --dot")
+		return fmt.Errorf("Please specify --dot, --tree or --compose")
 	}
 
 	return nil
@@ -109,12 +140,60 @@ func parseContainersJSON(rawJSON []byte) (*[]Container, error) {
 	err := json.Unmarshal(rawJSON, &containers)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error reading JSON: ", err)
+		return nil, newKindError(ErrParse, "Error reading JSON: %w", err)
 	}
 
 	return &containers, nil
 }
 
+// containerInspector is the part of the Docker client that inspects
+// containers.
+type containerInspector interface {
+	InspectContainer(id string) (*docker.Container, error)
+}
+
+// inspectLabels replaces each container's labels with the full set from
+// inspecting it, with at most concurrency calls in flight.  Containers that
+// can't be inspected keep the labels they were listed with, with a warning,
+// unless strict.
+func inspectLabels(client containerInspector, containers []Container, concurrency int, warnings io.Writer, strict bool) error {
+	errs := inParallel(len(containers), concurrency, func(index int) error {
+		var inspected *docker.Container
+		err := callWithTimeout(globalOptions.Timeout, func() error {
+			container, err := client.InspectContainer(containers[index].Id)
+			inspected = container
+			return err
+		})
+		if err == nil && inspected.Config != nil {
+			containers[index].Labels = inspected.Config.Labels
+		}
+		return err
+	})
+
+	for index, err := range errs {
+		if err == nil {
+			continue
+		}
+		if strict {
+			return fmt.Errorf("Unable to inspect container %s: %w", truncate(containers[index].Id), err)
+		}
+		fmt.Fprintf(warnings, "Warning: unable to inspect container %s: %s\n", truncate(containers[index].Id), err)
+	}
+	return nil
+}
+
+// containerName returns the container's own name, skipping the aliases that
+// links give it.
+func containerName(container Container) string {
+	var containerName string
+	for _, name := range container.Names {
+		if strings.Count(name, "/") == 1 {
+			containerName = name[1:]
+		}
+	}
+	return containerName
+}
+
 func jsonContainersToDot(containers *[]Container) string {
 
 	var buffer bytes.Buffer
@@ -122,13 +201,7 @@ func jsonContainersToDot(containers *[]Container) string {
 
 	for _, container := range *containers {
 
-		var containerName string
-
-		for _, name := range container.Names {
-			if strings.Count(name, "/") == 1 {
-				containerName = name[1:]
-			}
-		}
+		containerName := containerName(container)
 		for _, name := range container.Names {
 			nameParts := strings.Split(name, "/")
 			if len(nameParts) > 2 {
@@ -152,6 +225,94 @@ func jsonContainersToDot(containers *[]Container) string {
 	return buffer.String()
 }
 
+// containersTree prints the image tree with each container listed under the
+// image it runs, matched by image id as tags may have moved since.  Images in
+// use stay visible even when untagged.
+func containersTree(images []Image, containers []Container, opts TreeOptions) string {
+	opts.Containers = make(map[string][]Container)
+	for _, container := range containers {
+		opts.Containers[container.ImageID] = append(opts.Containers[container.ImageID], container)
+	}
+	for index := range images {
+		images[index].Containers = len(opts.Containers[images[index].Id])
+	}
+
+	roots := collectRoots(&images)
+	byParent := collectChildren(&images)
+	_, byParent = filterImages(&images, &byParent, true)
+	return jsonToTree(roots, byParent, opts)
+}
+
+// composeContainersToDot draws containers clustered by their compose project,
+// with an edge from each service's containers to the containers of the
+// services it depends on.  Containers without a project are clustered as
+// "standalone".
+func composeContainersToDot(containers []Container) string {
+	byProject := make(map[string][]Container)
+	for _, container := range containers {
+		project := container.Labels["com.docker.compose.project"]
+		if len(project) == 0 {
+			project = "standalone"
+		}
+		byProject[project] = append(byProject[project], container)
+	}
+
+	var projects []string
+	for project := range byProject {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("digraph docker {\n")
+
+	for _, project := range projects {
+		members := byProject[project]
+		sort.Slice(members, func(i, j int) bool {
+			return containerName(members[i]) < containerName(members[j])
+		})
+
+		buffer.WriteString(fmt.Sprintf(" subgraph \"cluster_%s\" {\n  label=\"%s\";\n", project, project))
+		byService := make(map[string][]string)
+		for _, container := range members {
+			if service := container.Labels["com.docker.compose.service"]; len(service) > 0 {
+				byService[service] = append(byService[service], containerName(container))
+			}
+
+			background := "paleturquoise"
+			if strings.Contains(container.Status, "Exited") {
+				background = "lightgrey"
+			}
+			buffer.WriteString(fmt.Sprintf("  \"%s\" [label=\"%s\\n%s\",shape=box,fillcolor=\"%s\",style=\"filled,rounded\"];\n", containerName(container), containerName(container), truncate(container.Id), background))
+		}
+		buffer.WriteString(" }\n")
+
+		for _, container := range members {
+			for _, dependency := range composeDependencies(container.Labels["com.docker.compose.depends_on"]) {
+				for _, target := range byService[dependency] {
+					buffer.WriteString(fmt.Sprintf(" \"%s\" -> \"%s\"\n", containerName(container), target))
+				}
+			}
+		}
+	}
+
+	buffer.WriteString("}\n")
+
+	return buffer.String()
+}
+
+// composeDependencies parses the services named in compose's depends_on
+// label, e.g. "db:service_healthy:false,cache:service_started:false".
+func composeDependencies(label string) []string {
+	var services []string
+	for _, entry := range strings.Split(label, ",") {
+		if service := strings.SplitN(entry, ":", 2)[0]; len(service) > 0 {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
 func init() {
 	parser.AddCommand("containers",
 		"Visualize docker containers.",
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func Test_ComposeContainers(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/compose_containers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers, err := parseContainersJSON(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "dot_compose_containers.golden", composeContainersToDot(*containers))
+}
+
+func Test_ContainersTree(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	containers := []Container{
+		{Id: "aaaaaaaaaaaaaaaa", Image: "foo:latest", ImageID: "c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470", Names: []string{"/web"}, Status: "Up 2 hours"},
+		{Id: "bbbbbbbbbbbbbbbb", Image: "aaf8d4d1bcca", ImageID: "aaf8d4d1bccab994574c5f626147582d2ae3735f5db5f2c87be8e5e697c08870", Names: []string{"/scratch"}, Status: "Exited (1) 2 days ago"},
+		// started as foo:latest, but the tag has moved on since
+		{Id: "cccccccccccccccc", Image: "foo:latest", ImageID: "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", Names: []string{"/db"}, Status: "Up 5 minutes"},
+	}
+
+	result := containersTree(*im, containers, TreeOptions{NoSizeLabel: true, Incremental: true})
+	expected := `└─4c1208b690c6 662.6 MB
+  ├─c87be8e5e697 2.0 MB Tags: foo:latest
+  │ └─aaaaaaaaaaaa Container: web Status: Up 2 hours
+  └─574c5faaf8d4 30.0 MB Tags: base:latest
+    ├─cccccccccccc Container: db Status: Up 5 minutes
+    └─aaf8d4d1bcca 40.0 MB
+      └─bbbbbbbbbbbb Container: scratch Status: Exited (1) 2 days ago
+`
+	if result != expected {
+		t.Fatalf("containers tree was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_UsedImagesOnlyKeptInContainersTree(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["base:latest"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Containers":1},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	// the image tree folds used intermediate layers away as before
+	roots, byParent := prepareTree(im, nil, false)
+	result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true})
+	expected := `└─111111111111 0.0 B Tags: base:latest
+  └─333333333333 0.0 B Tags: app:latest
+`
+	if result != expected {
+		t.Fatalf("image tree was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+type stubInspector struct {
+	mutex    sync.Mutex
+	inFlight int
+	most     int
+}
+
+func (s *stubInspector) InspectContainer(id string) (*docker.Container, error) {
+	s.mutex.Lock()
+	s.inFlight++
+	if s.inFlight > s.most {
+		s.most = s.inFlight
+	}
+	s.mutex.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	s.mutex.Lock()
+	s.inFlight--
+	s.mutex.Unlock()
+
+	if id == "gone" {
+		return nil, errors.New("no such container")
+	}
+	return &docker.Container{ID: id, Config: &docker.Config{Labels: map[string]string{"id": id}}}, nil
+}
+
+func Test_InspectLabels(t *testing.T) {
+	containers := []Container{{Id: "1"}, {Id: "2"}, {Id: "gone", Labels: map[string]string{"listed": "yes"}}, {Id: "3"}, {Id: "4"}}
+	inspector := &stubInspector{}
+
+	var warnings bytes.Buffer
+	if err := inspectLabels(inspector, containers, 2, &warnings, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, container := range containers {
+		if container.Id == "gone" {
+			if container.Labels["listed"] != "yes" {
+				t.Errorf("container that could not be inspected lost its labels: %v", container.Labels)
+			}
+		} else if container.Labels["id"] != container.Id {
+			t.Errorf("container %s was given labels %v", container.Id, container.Labels)
+		}
+	}
+	if warnings.String() != "Warning: unable to inspect container gone: no such container\n" {
+		t.Fatalf("unexpected warnings '%s'", warnings.String())
+	}
+	if inspector.most > 2 {
+		t.Fatalf("%d containers were inspected at once, expected at most 2", inspector.most)
+	}
+
+	if err := inspectLabels(&stubInspector{}, containers, 2, &bytes.Buffer{}, true); err == nil {
+		t.Fatal("failed inspection did not cause an error in strict mode")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// daemonInfo describes the Docker daemon connected to.
+type daemonInfo struct {
+	Version    string
+	APIVersion string
+
+	// address connected to, e.g. tcp://build-host:2376
+	Endpoint string
+}
+
+// daemon is filled in by connect, and its version by probeDaemon; it is empty
+// when reading from stdin or when the daemon did not report its version.
+var daemon daemonInfo
+
+// versionClient is the part of the Docker client needed to detect versions.
+type versionClient interface {
+	Version() (*docker.Env, error)
+}
+
+func detectDaemon(client versionClient) (daemonInfo, error) {
+	env, err := client.Version()
+	if err != nil {
+		return daemonInfo{}, err
+	}
+
+	return daemonInfo{Version: env.Get("Version"), APIVersion: env.Get("ApiVersion")}, nil
+}
+
+// probeDaemon asks the daemon for its version, unless it is already known.
+// An unreachable daemon is reported by the first real call instead, so the
+// error is only for callers that show the version.
+func probeDaemon(client versionClient) error {
+	if len(daemon.APIVersion) > 0 {
+		return nil
+	}
+	info, err := detectDaemon(client)
+	if err != nil {
+		return err
+	}
+	daemon.Version, daemon.APIVersion = info.Version, info.APIVersion
+	return nil
+}
+
+// minimum API versions of the daemon for flags that depend on it
+var featureAPIVersions = map[string]string{
+	"--digests":         "1.18",
+	"--merge-by-digest": "1.18",
+	"--history":         "1.17",
+}
+
+// checkFeatures warns about the given flags when the daemon's API version is
+// too old to support them.  In strict mode the warnings turn into an error.
+// Nothing is checked when the API version is unknown.
+func checkFeatures(apiVersion string, flags []string, warnings io.Writer, strict bool) error {
+	if len(apiVersion) == 0 {
+		return nil
+	}
+
+	unsupported := 0
+	for _, flag := range flags {
+		minimum, gated := featureAPIVersions[flag]
+		if gated && compareAPIVersions(apiVersion, minimum) < 0 {
+			fmt.Fprintf(warnings, "Warning: %s needs Docker API %s or newer, but the daemon speaks %s\n", flag, minimum, apiVersion)
+			unsupported++
+		}
+	}
+	if strict && unsupported > 0 {
+		return fmt.Errorf("Found %d flags the daemon is too old to support.", unsupported)
+	}
+
+	return nil
+}
+
+// compareAPIVersions compares dotted version numbers such as 1.18 and 1.9,
+// returning -1, 0 or 1.
+func compareAPIVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for index := 0; index < len(aParts) || index < len(bParts); index++ {
+		var aPart, bPart int
+		if index < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[index])
+		}
+		if index < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[index])
+		}
+		if aPart != bPart {
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
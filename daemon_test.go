@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+type stubVersionClient struct {
+	env   docker.Env
+	calls int
+}
+
+func (c *stubVersionClient) Version() (*docker.Env, error) {
+	c.calls++
+	return &c.env, nil
+}
+
+func Test_CheckFeatures(t *testing.T) {
+	info, err := detectDaemon(&stubVersionClient{env: docker.Env{"Version=1.5.0", "ApiVersion=1.17"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.5.0" || info.APIVersion != "1.17" {
+		t.Fatalf("unexpected daemon info %+v", info)
+	}
+
+	var warnings bytes.Buffer
+	if err := checkFeatures(info.APIVersion, []string{"--digests", "--history"}, &warnings, false); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.String() != "Warning: --digests needs Docker API 1.18 or newer, but the daemon speaks 1.17\n" {
+		t.Fatalf("unexpected warning '%s'", warnings.String())
+	}
+	if err := checkFeatures(info.APIVersion, []string{"--digests"}, &bytes.Buffer{}, true); err == nil {
+		t.Fatal("old daemon did not cause an error in strict mode")
+	}
+
+	for _, apiVersion := range []string{"1.18", "1.41", ""} {
+		warnings.Reset()
+		if err := checkFeatures(apiVersion, []string{"--digests"}, &warnings, true); err != nil || warnings.Len() > 0 {
+			t.Errorf("API %s was rejected: %v '%s'", apiVersion, err, warnings.String())
+		}
+	}
+
+	warnings.Reset()
+	if err := checkFeatures("1.16", []string{"--history"}, &warnings, false); err != nil || warnings.String() != "Warning: --history needs Docker API 1.17 or newer, but the daemon speaks 1.16\n" {
+		t.Fatalf("old daemon did not warn about --history: %v '%s'", err, warnings.String())
+	}
+
+	if compareAPIVersions("1.9", "1.18") != -1 || compareAPIVersions("1.18", "1.18.0") != 0 || compareAPIVersions("2.0", "1.41") != 1 {
+		t.Fatal("API versions compared as strings")
+	}
+}
+
+func Test_ProbeDaemon(t *testing.T) {
+	original := daemon
+	defer func() { daemon = original }()
+
+	daemon = daemonInfo{Endpoint: "unix:///var/run/docker.sock"}
+	client := &stubVersionClient{env: docker.Env{"Version=20.10.0", "ApiVersion=1.41"}}
+	for i := 0; i < 2; i++ {
+		if err := probeDaemon(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if daemon.APIVersion != "1.41" || daemon.Endpoint != "unix:///var/run/docker.sock" || client.calls != 1 {
+		t.Fatalf("unexpected daemon info %+v after %d calls", daemon, client.calls)
+	}
+}
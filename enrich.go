@@ -0,0 +1,146 @@
+package main
+
+import (
+	"github.com/fsouza/go-dockerclient"
+
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// historyClient is the part of the Docker client needed to fetch histories.
+type historyClient interface {
+	ImageHistory(name string) ([]docker.ImageHistory, error)
+}
+
+// callWithTimeout runs call, giving up on it after timeout (never when the
+// timeout is 0).  The Docker client can't cancel these calls, so one that
+// times out is left to finish in the background and its result is dropped.
+func callWithTimeout(timeout time.Duration, call func() error) error {
+	if timeout <= 0 {
+		return call()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// timeoutHistoryClient gives up on history calls that take too long.
+type timeoutHistoryClient struct {
+	client  historyClient
+	timeout time.Duration
+}
+
+func (c timeoutHistoryClient) ImageHistory(name string) ([]docker.ImageHistory, error) {
+	var history []docker.ImageHistory
+	err := callWithTimeout(c.timeout, func() error {
+		fetched, err := c.client.ImageHistory(name)
+		history = fetched
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// inParallel calls call with each index below count, with at most
+// concurrency calls in flight, and returns the error of each call by index.
+func inParallel(count int, concurrency int, call func(index int) error) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	indexes := make(chan int)
+	errs := make([]error, count)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				errs[index] = call(index)
+			}
+		}()
+	}
+
+	for index := 0; index < count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
+
+// enrichImages calls fetch for every image, with at most concurrency calls in
+// flight.  Each call only touches its own image, so the order of the slice is
+// unchanged.  Failed calls leave their image as it was and are reported as
+// warnings, naming the image by idLength characters of its id; in strict mode the error for the first such image is returned
+// instead.
+func enrichImages(images []Image, concurrency int, idLength int, fetch func(image *Image) error, warnings io.Writer, strict bool) error {
+	errs := inParallel(len(images), concurrency, func(index int) error {
+		return fetch(&images[index])
+	})
+
+	for index, err := range errs {
+		if err == nil {
+			continue
+		}
+		if strict {
+			return fmt.Errorf("Unable to fetch details for image %s: %w", truncateTo(images[index].Id, idLength), err)
+		}
+		fmt.Fprintf(warnings, "Warning: unable to fetch details for image %s: %s\n", truncateTo(images[index].Id, idLength), err)
+	}
+
+	return nil
+}
+
+// fetchHistories fills in the layer history of every image, newest layer
+// first.
+func fetchHistories(client historyClient, images []Image, concurrency int, idLength int, warnings io.Writer, strict bool) error {
+	return enrichImages(images, concurrency, idLength, func(image *Image) error {
+		history, err := client.ImageHistory(image.Id)
+		if err != nil {
+			return err
+		}
+
+		image.History = nil
+		for _, entry := range history {
+			image.History = append(image.History, Layer{
+				Id:        entry.ID,
+				CreatedBy: entry.CreatedBy,
+				Size:      entry.Size,
+				Created:   entry.Created,
+			})
+		}
+
+		return nil
+	}, warnings, strict)
+}
+
+// countContainers records how many containers use each image.  Containers
+// refer to their image by id or by name, so both are resolved.
+func countContainers(images []Image, containers []docker.APIContainers) {
+	counts := make(map[string]int)
+	for _, container := range containers {
+		if image, err := findStartImage(container.Image, &images); err == nil {
+			counts[image.Id]++
+		}
+	}
+
+	for index := range images {
+		images[index].Containers = counts[images[index].Id]
+	}
+}
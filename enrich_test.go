@@ -0,0 +1,163 @@
+package main
+
+import (
+	"github.com/fsouza/go-dockerclient"
+
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stubHistoryClient struct {
+	mutex    sync.Mutex
+	inFlight int
+	maxSeen  int
+	failFor  string
+	hangFor  string
+	hang     chan struct{}
+}
+
+func (c *stubHistoryClient) ImageHistory(name string) ([]docker.ImageHistory, error) {
+	c.mutex.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxSeen {
+		c.maxSeen = c.inFlight
+	}
+	c.mutex.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mutex.Lock()
+	c.inFlight--
+	c.mutex.Unlock()
+
+	if name == c.hangFor {
+		<-c.hang
+	}
+	if name == c.failFor {
+		return nil, errors.New("daemon went away")
+	}
+
+	return []docker.ImageHistory{
+		{ID: name, CreatedBy: "/bin/sh -c #(nop) CMD [\"" + name + "\"]", Size: 10},
+	}, nil
+}
+
+func Test_FetchHistoriesTimeout(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	images := *im
+	slow := images[2].Id
+
+	client := &stubHistoryClient{hangFor: slow, hang: make(chan struct{})}
+	defer close(client.hang)
+
+	var warnings bytes.Buffer
+	if err := fetchHistories(timeoutHistoryClient{client, 50 * time.Millisecond}, images, 2, 12, &warnings, false); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.String() != "Warning: unable to fetch details for image 574c5faaf8d4: timed out after 50ms\n" {
+		t.Fatalf("unexpected warnings '%s'", warnings.String())
+	}
+	for _, image := range images {
+		if image.Id == slow && len(image.History) != 0 {
+			t.Fatal("the timed out image was given a history")
+		} else if image.Id != slow && len(image.History) != 1 {
+			t.Fatalf("image %s has no history", truncate(image.Id))
+		}
+	}
+
+	if err := fetchHistories(timeoutHistoryClient{client, 50 * time.Millisecond}, images, 2, 12, ioutil.Discard, true); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error in strict mode, got %v", err)
+	}
+}
+
+func Test_FetchHistories(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	images := *im
+
+	client := &stubHistoryClient{}
+	if err := fetchHistories(client, images, 2, 12, ioutil.Discard, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.maxSeen > 2 {
+		t.Fatalf("saw %d concurrent calls, expected at most 2", client.maxSeen)
+	}
+
+	expected := []string{"c87be8e5e697", "626147582d2a", "574c5faaf8d4", "aaf8d4d1bcca", "735f5db56261", "4c1208b690c6"}
+	for index, image := range images {
+		if truncate(image.Id) != expected[index] {
+			t.Fatalf("image order changed: got %s at %d, expected %s", truncate(image.Id), index, expected[index])
+		}
+		if len(image.History) != 1 || image.History[0].Id != image.Id {
+			t.Fatalf("image %s was not enriched: %v", truncate(image.Id), image.History)
+		}
+	}
+
+	roots, byParent := prepareTree(&images, nil, false)
+	result := jsonToTree(roots, byParent, TreeOptions{ShowHistory: true})
+	if !regexp.MustCompile(`(?m)c87be8e5e697 .* Tags: foo:latest Cmd: CMD \["c87be8e5e697`).MatchString(result) {
+		t.Fatalf("history missing from tree '%s'", result)
+	}
+}
+
+func Test_FetchHistoriesError(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+
+	client := &stubHistoryClient{failFor: "735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470"}
+	err := fetchHistories(client, *im, 8, 12, ioutil.Discard, true)
+	if err == nil || err.Error() != "Unable to fetch details for image 735f5db56261: daemon went away" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func Test_FetchHistoriesPartial(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	images := *im
+
+	var warnings bytes.Buffer
+	client := &stubHistoryClient{failFor: "735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470"}
+	if err := fetchHistories(client, images, 8, 12, &warnings, false); err != nil {
+		t.Fatalf("failure was fatal without strict: %v", err)
+	}
+	if warnings.String() != "Warning: unable to fetch details for image 735f5db56261: daemon went away\n" {
+		t.Fatalf("unexpected warnings '%s'", warnings.String())
+	}
+
+	for _, image := range images {
+		if failed := truncate(image.Id) == "735f5db56261"; failed != (len(image.History) == 0) {
+			t.Fatalf("unexpected history for %s: %v", truncate(image.Id), image.History)
+		}
+	}
+
+	roots, byParent := prepareTree(&images, nil, true)
+	result := jsonToTree(roots, byParent, TreeOptions{ShowHistory: true})
+	if strings.Count(result, "\n") != 6 || !strings.Contains(result, "735f5db56261 Virtual Size: 672.6 MB\n") {
+		t.Fatalf("tree was not rendered with the remaining data '%s'", result)
+	}
+}
+
+func Test_CountContainers(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	images := *im
+
+	countContainers(images, []docker.APIContainers{
+		{ID: "1", Image: "foo"},
+		{ID: "2", Image: "foo:latest"},
+		{ID: "3", Image: "574c5faaf8d4"},
+		{ID: "4", Image: "gone:latest"},
+	})
+
+	counts := make(map[string]int)
+	for _, image := range images {
+		counts[truncate(image.Id)] = image.Containers
+	}
+	if counts["c87be8e5e697"] != 2 || counts["574c5faaf8d4"] != 1 || counts["4c1208b690c6"] != 0 {
+		t.Fatalf("unexpected container counts %v", counts)
+	}
+}
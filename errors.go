@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors, for use with errors.Is.
+var (
+	ErrNoDaemon      = errors.New("unable to connect to the Docker daemon")
+	ErrParse         = errors.New("unable to parse input")
+	ErrImageNotFound = errors.New("image not found")
+)
+
+// kindError is an error of one of the kinds above, wrapping its cause (if
+// any) so that errors.Is matches both.
+type kindError struct {
+	kind    error
+	message string
+	cause   error
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Unwrap() error {
+	return e.cause
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// newKindError formats a message (which may wrap a cause with %w) and tags
+// the resulting error with its kind.
+func newKindError(kind error, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	return &kindError{kind: kind, message: err.Error(), cause: errors.Unwrap(err)}
+}
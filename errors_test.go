@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_ErrorKinds(t *testing.T) {
+	_, err := parseImagesJSON([]byte(`[{"Id": `))
+	var syntaxError *json.SyntaxError
+	if !errors.Is(err, ErrParse) || !errors.As(err, &syntaxError) {
+		t.Errorf("bad images json gave %v, expected ErrParse wrapping a syntax error", err)
+	}
+
+	if _, err := parseContainersJSON([]byte(`{`)); !errors.Is(err, ErrParse) {
+		t.Errorf("bad containers json gave %v, expected ErrParse", err)
+	}
+
+	if _, err := parseCompose([]byte("services: [")); !errors.Is(err, ErrParse) {
+		t.Errorf("bad compose file gave %v, expected ErrParse", err)
+	}
+
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	_, err = findStartImage("no-such-image", im)
+	if !errors.Is(err, ErrImageNotFound) || errors.Is(err, ErrParse) {
+		t.Errorf("missing image gave %v, expected only ErrImageNotFound", err)
+	}
+	if err.Error() != "Unable to find image no-such-image = no-such-image:latest." {
+		t.Errorf("unexpected message '%s'", err)
+	}
+
+	original, originalSleep := globalOptions, sleep
+	defer func() { globalOptions, sleep = original, originalSleep }()
+	sleep = func(time.Duration) {}
+	globalOptions.Retries = 1
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	if _, err := connect(); !errors.Is(err, ErrNoDaemon) {
+		t.Errorf("unreachable daemon gave %v, expected ErrNoDaemon", err)
+	}
+}
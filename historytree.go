@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// treeFromHistory rebuilds the parent links of images from their layer
+// histories, for images from daemons that no longer report parent ids.
+// Images whose histories start with the same layers share those layers as
+// ancestors.  Shared layers that are not images themselves are added as
+// untagged images.  Images without a history keep their parent id.
+func treeFromHistory(images []Image) []Image {
+	// each image's layers as a path of keys, oldest layer first
+	paths := make([][]string, len(images))
+	owners := make(map[string]string)
+	for index, image := range images {
+		key := ""
+		for layer := len(image.History) - 1; layer >= 0; layer-- {
+			entry := image.History[layer]
+			key = fmt.Sprintf("%s\n%d %d %s", key, entry.Created, entry.Size, entry.CreatedBy)
+			paths[index] = append(paths[index], key)
+		}
+		if len(key) > 0 {
+			if _, exists := owners[key]; !exists {
+				owners[key] = image.Id
+			}
+		}
+	}
+
+	var layers []Image
+	result := make([]Image, len(images))
+	for index, image := range images {
+		path := paths[index]
+		result[index] = image
+		if len(path) == 0 {
+			continue
+		}
+
+		var virtualSize int64
+		parentId := ""
+		for depth, key := range path[:len(path)-1] {
+			entry := image.History[len(path)-1-depth]
+			virtualSize += entry.Size
+
+			// a shared layer that is not an image itself stands in for one
+			id, exists := owners[key]
+			if !exists {
+				id = fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+				owners[key] = id
+				layers = append(layers, Image{
+					Id:          id,
+					ParentId:    parentId,
+					RepoTags:    []string{"<none>:<none>"},
+					Size:        entry.Size,
+					VirtualSize: virtualSize,
+					Created:     entry.Created,
+				})
+			}
+			parentId = id
+		}
+		result[index].ParentId = parentId
+	}
+
+	return append(result, layers...)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_TreeFromHistory(t *testing.T) {
+	rootfs := Layer{Id: "<missing>", CreatedBy: "/bin/sh -c #(nop) ADD file:rootfs in /", Size: 5000, Created: 100}
+	cmd := Layer{Id: "<missing>", CreatedBy: "/bin/sh -c #(nop) CMD [\"sh\"]", Size: 0, Created: 101}
+
+	images := []Image{
+		{Id: "app00000000000000", RepoTags: []string{"app:latest"}, History: []Layer{
+			{Id: "app00000000000000", CreatedBy: "/bin/sh -c make install", Size: 300, Created: 300},
+			{Id: "<missing>", CreatedBy: "/bin/sh -c apk add make", Size: 200, Created: 200},
+			cmd, rootfs,
+		}},
+		{Id: "base0000000000000", RepoTags: []string{"base:latest"}, History: []Layer{
+			{Id: "base0000000000000", CreatedBy: "/bin/sh -c apk add make", Size: 200, Created: 200},
+			cmd, rootfs,
+		}},
+		{Id: "tool0000000000000", RepoTags: []string{"tool:latest"}, History: []Layer{
+			{Id: "tool0000000000000", CreatedBy: "/bin/sh -c apk add curl", Size: 100, Created: 250},
+			cmd, rootfs,
+		}},
+		{Id: "lone0000000000000", RepoTags: []string{"lone:latest"}},
+	}
+
+	rebuilt := treeFromHistory(images)
+	if len(rebuilt) != 6 {
+		t.Fatalf("expected 4 images and 2 shared layers, got %d images", len(rebuilt))
+	}
+
+	byId := make(map[string]Image)
+	for _, image := range rebuilt {
+		byId[image.Id] = image
+	}
+	cmdLayer := byId[byId["base0000000000000"].ParentId]
+	osLayer := byId[cmdLayer.ParentId]
+
+	if byId["app00000000000000"].ParentId != "base0000000000000" {
+		t.Errorf("app was not placed under base: %s", byId["app00000000000000"].ParentId)
+	}
+	if byId["tool0000000000000"].ParentId != cmdLayer.Id {
+		t.Errorf("tool does not share base's lower layers: %s", byId["tool0000000000000"].ParentId)
+	}
+	if cmdLayer.Size != 0 || cmdLayer.VirtualSize != 5000 || isTagged(cmdLayer) {
+		t.Errorf("unexpected shared layer %+v", cmdLayer)
+	}
+	if osLayer.ParentId != "" || osLayer.Size != 5000 || len(osLayer.Id) != 64 {
+		t.Errorf("unexpected root layer %+v", osLayer)
+	}
+	if byId["lone0000000000000"].ParentId != "" {
+		t.Errorf("image without history was moved: %+v", byId["lone0000000000000"])
+	}
+
+	roots := collectRoots(&rebuilt)
+	if len(roots) != 2 {
+		t.Fatalf("expected the os layer and lone as roots, got %d roots", len(roots))
+	}
+}
@@ -5,10 +5,22 @@ import (
 
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 )
 
 type Image struct {
@@ -18,107 +30,678 @@ type Image struct {
 	VirtualSize int64
 	Size        int64
 	Created     int64
+	RepoDigests []string          `json:",omitempty"`
+	History     []Layer           `json:",omitempty"`
+	Containers  int               `json:",omitempty"`
+	Labels      map[string]string `json:",omitempty"`
+
+	// set when ParentId was guessed by --infer-parents
+	InferredParent bool `json:"-"`
+}
+
+type Layer struct {
+	Id        string
+	CreatedBy string `json:",omitempty"`
+	Size      int64
+	Created   int64
 }
 
 type ImagesCommand struct {
-	Dot          bool `short:"d" long:"dot" description:"Show image information as Graphviz dot. You can add a start image id or name -d/--dot [id/name]"`
-	Tree         bool `short:"t" long:"tree" description:"Show image information as tree. You can add a start image id or name -t/--tree [id/name]"`
-	Short        bool `short:"s" long:"short" description:"Show short summary of images (repo name and list of tags)."`
-	NoTruncate   bool `short:"n" long:"no-trunc" description:"Don't truncate the image IDs."`
-	Incremental  bool `short:"i" long:"incremental" description:"Display image size as incremental rather than cumulative."`
-	OnlyLabelled bool `short:"l" long:"only-labelled" description:"Print only labelled images/containers."`
+	Dot          bool          `short:"d" long:"dot" description:"Show image information as Graphviz dot. You can add a start image id or name -d/--dot [id/name]"`
+	Tree         bool          `short:"t" long:"tree" description:"Show image information as tree. You can add a start image id or name -t/--tree [id/name]"`
+	Short        bool          `short:"s" long:"short" description:"Show short summary of images (repo name and list of tags)."`
+	AgeHistogram bool          `long:"age-histogram" description:"Show counts and sizes of images bucketed by age."`
+	Treemap      bool          `long:"treemap" description:"Show image sizes as an SVG treemap, colored by repository. Uses incremental sizes with --incremental."`
+	Prometheus   string        `long:"prometheus" value-name:"FILE" description:"Write image sizes, the image count and reclaimable space as Prometheus metrics to FILE (- for stdout)."`
+	StatsJSON    bool          `long:"stats-json" description:"Show summary statistics about the images as JSON."`
+	Timeline     bool          `long:"timeline" description:"Show, per day of creation, how many images were created and which tags they carry."`
+	TimeZone     string        `long:"tz" value-name:"zone" default:"Local" description:"Time zone whose calendar days --timeline groups by, e.g. UTC or Europe/Berlin."`
+	AgeBuckets   string        `long:"age-buckets" value-name:"24h,168h,..." description:"Comma separated, increasing age thresholds for --age-histogram."`
+	JSON         bool          `long:"json" description:"Show the tree as nested JSON. You can add a start image id or name."`
+	FoldUntagged bool          `long:"fold-untagged" description:"In --json output, keep all layers but group the untagged children of each image under one synthetic node."`
+	JSONKeys     string        `long:"json-keys" choice:"camel" choice:"snake" default:"camel" description:"Key casing of JSON output (--json, --ndjson, --stats-json)."`
+	OmitEmpty    bool          `long:"json-omit-empty" description:"Leave null, zero, false and empty values out of JSON output."`
+	PrintSchema  bool          `long:"print-schema" description:"Print the JSON Schema of the --json output (or, with --ndjson, of each line) and exit."`
+	NDJSON       bool          `long:"ndjson" description:"Show the tree as newline delimited JSON, one image per line. You can add a start image id or name."`
+	OnlyRoots    bool          `long:"only-roots" description:"List only the root images, ordered like --sort-repos-by."`
+	SharedLayers bool          `long:"duplicate-layers" description:"List the layers that appear in the histories of images from separate lineages."`
+	Savings      bool          `long:"shared-savings" description:"After the tree, show how much space sharing layers saves compared to unshared images."`
+	Registry     string        `long:"registry" value-name:"URL" description:"List the tags a v2 registry has for the repository given as argument, and whether each exists locally."`
+	Verify       bool          `long:"verify" description:"Compare the image JSON on stdin with the daemon's images, reporting missing images and size differences."`
+	RepoDiff     bool          `long:"repo-diff" description:"Compare the tags of the two repositories given as arguments."`
+	GroupByBase  bool          `long:"group-by-base" description:"Show how many images descend from each base image, and how much space they add."`
+	CountPrefix  string        `long:"count-prefix" value-name:"SEP" description:"Count the tags sharing each prefix before SEP in the tag (e.g. -), or in the repository path for /, with the size of their images."`
+	SortReposBy  string        `long:"sort-repos-by" choice:"name" choice:"tags" choice:"size" choice:"lineage" default:"name" description:"Order of the repositories in short output, and of the roots with --only-roots. lineage puts the repositories and roots with the most built on them first, also with --group-by-base."`
+	Reverse      bool          `long:"reverse" description:"Reverse the order set by --sort-repos-by."`
+	Align        bool          `long:"align" description:"In short mode, pad repository names so their tags line up."`
+	MaxAlign     int           `long:"max-align-width" value-name:"N" default:"40" description:"With --align, don't pad repository names beyond N characters."`
+	LatestLast   bool          `long:"latest-last" description:"In short mode, list each repository's latest tag after its versions instead of first."`
+	Oneline      bool          `long:"oneline" description:"In short mode, print each repo:tag on its own line, sorted, for grep and wc -l."`
+	Flatten      bool          `long:"flatten" description:"In short mode, list one line per image with all of its tags, instead of one per repository."`
+	Expand       bool          `long:"expand" description:"With --short, follow each repository with the lineage of its images."`
+	WithAge      bool          `long:"with-age" description:"In short output, follow each tag with how long ago its image was created, e.g. latest (2d)."`
+	WithDepth    bool          `long:"with-depth" description:"In short output, show how deep each repository's images sit in the layer tree."`
+	TruncLength  int           `long:"trunc-length" default:"12" value-name:"N" description:"Show this many characters of image ids, more if needed to keep them distinct. Container ids keep 12."`
+	NoTruncate   bool          `short:"n" long:"no-trunc" description:"Don't truncate the image IDs."`
+	Incremental  bool          `short:"i" long:"incremental" description:"Display image size as incremental rather than cumulative."`
+	IndentStyle  string        `long:"indent-style" choice:"unicode" choice:"ascii" choice:"spaces" choice:"dots" default:"unicode" description:"Guides drawn to indent tree output."`
+	MaxWidth     int           `long:"max-width" value-name:"N" description:"Wrap tag lists in tree output at N columns. Defaults to the terminal width; a negative N never wraps."`
+	NoSizeLabel  bool          `long:"no-size-label" description:"Drop the 'Virtual Size:' label from tree lines."`
+	SizeFormat   string        `long:"format-size" choice:"si" choice:"iec" choice:"raw" default:"si" description:"Display sizes in SI (1000-based) or IEC (1024-based) units, or as raw bytes."`
+	History      bool          `long:"history" description:"Fetch each image's history and show the command that created it."`
+	Count        bool          `long:"count" description:"Only print how many images are left after filtering."`
+	SummaryOnly  bool          `long:"summary-only" description:"Instead of the selected output, print one line with the image count, total size and largest image."`
+	FailOver     string        `long:"fail-over" value-name:"SIZE" description:"Fail, listing them, if any images are larger than SIZE (e.g. 500MB or 2GiB)."`
+	Usage        bool          `long:"usage" description:"Show how many containers use each image."`
+	Digests      bool          `long:"digests" description:"Show image digests."`
+	MergeDigests bool          `long:"merge-by-digest" description:"Show images that share a content digest, such as copies left behind by a migration, as one image with all of their tags."`
+	Concurrency  int           `long:"concurrency" default:"8" description:"Number of images to fetch details for at once."`
+	OnlyLabelled bool          `short:"l" long:"only-labelled" description:"Print only labelled images/containers."`
+	IgnoreEmpty  bool          `long:"ignore-empty" description:"Drop images whose size and virtual size are both zero, such as scratch placeholders, attaching their children to their parent."`
+	HasLabels    bool          `long:"has-labels" description:"Only show images with Docker labels (LABEL in the Dockerfile), and their ancestors. Unlike --only-labelled, this ignores tags."`
+	Render       string        `long:"render" choice:"svg" choice:"png" description:"Render the dot graph with Graphviz into the given format."`
+	SplitByRepo  string        `long:"split-by-repo" value-name:"DIR" description:"Write a separate dot file for each repository into DIR."`
+	Output       string        `short:"o" long:"output" value-name:"FILE" description:"Write dot, JSON or rendered output (--render, --treemap) to FILE instead of stdout. Without one of those flags, the format follows FILE's extension (.dot, .json, .svg or .png)."`
+	DryRun       bool          `long:"dry-run" description:"Log the files -o, --render, --treemap, --split-by-repo or --prometheus would write, with their format and size, without writing them."`
+	Rename       []string      `long:"rename" value-name:"old=new" description:"Show repositories starting with old as starting with new instead. Repeatable."`
+	ShortNames   bool          `long:"short-names" description:"Show tags without their registry host, e.g. team/app:latest for registry.corp/team/app:latest. Patterns still match the full names."`
+	NoNamespace  bool          `long:"drop-namespace" description:"With --short-names, leave out the namespace as well, e.g. app:latest."`
+	LatestOnly   bool          `long:"latest-only" description:"Only show the :latest tag of each repository, or its highest version tag when there is no :latest."`
+	MarkDangling bool          `long:"mark-dangling" description:"In dot output, draw dangling (untagged, childless) images gray and dashed. Implies --all-layers, as dangling images are hidden otherwise."`
+	MaxNodes     int           `long:"max-nodes" value-name:"N" description:"In dot output, only draw the N largest images and their ancestors, noting how many were left out."`
+	Title        string        `long:"title" value-name:"text" description:"Caption the dot graph with this title; 'auto' uses the daemon's host and the current date."`
+	BaseName     string        `long:"base-name" value-name:"name" default:"base" description:"In dot output, the id of the invisible node the roots hang from, for combining graphs."`
+	LabelsOnly   bool          `long:"labels-only" description:"In dot output, label tagged images with their tags only, leaving out the ids."`
+	FullIdTitle  bool          `long:"full-id-title" description:"In dot output, give each node its full image id as SVG element id and tooltip, keeping the label short."`
+	Concentrate  bool          `long:"concentrate" description:"In dot output, merge parallel edges to reduce clutter in dense graphs."`
+	LabelShape   string        `long:"label-shape" choice:"box" choice:"record" default:"box" description:"In dot output, draw labelled images as rounded boxes or as records with one field per line."`
+	ReposOnly    bool          `long:"repos-only" description:"In dot output, draw one node per repository, with edges from the repositories others build on."`
+	TagNodes     bool          `long:"tag-nodes" description:"In dot output, draw each tag as its own node pointing at its image."`
+	AllNodes     bool          `long:"all-nodes" description:"Draw a box for every image in dot output, including untagged ones."`
+	ColorByAge   bool          `long:"color-by-age" description:"Color dot nodes from fresh (green) to old (gray) by creation time."`
+	ColorScheme  string        `long:"color-scheme" choice:"default" choice:"viridis" choice:"colorblind" choice:"grayscale" default:"default" description:"Colors for --color-by-age and --treemap."`
+	Legend       bool          `long:"legend" description:"With --color-by-age, add a legend of the colors' creation dates to the dot output."`
+	LayerCount   bool          `long:"layer-count" description:"Annotate tagged images in tree output with the number of layers they are built from."`
+	Reclaimable  bool          `long:"reclaimable" description:"Mark the images that can be removed (untagged, without children or containers) and total their size. Implies --all-layers, as such images are hidden otherwise."`
+	PrunePlan    bool          `long:"prune-plan" description:"Print a shell script of docker rmi commands removing the untagged images nothing depends on, children first."`
+	RepoTotals   bool          `long:"repo-totals" description:"After the tree, list each repository with its image count and size."`
+	RecentSince  time.Duration `long:"recent-since" value-name:"duration" description:"In tree and dot output, highlight images created within this duration and dim older ones, e.g. 72h."`
+	NewerThan    time.Duration `long:"newer-than" value-name:"duration" description:"Only show images created within this duration, e.g. 168h. Filtered locally, as the daemon cannot filter images by time."`
+	SinceImage   string        `long:"since-image" value-name:"id/name" description:"Only show the layers the start image adds on top of this base image."`
+	AncestorOf   string        `long:"ancestor-of" value-name:"id/name" description:"Only show this image and its ancestors, the lineage it was built on."`
+	DescendantOf string        `long:"descendant-of" value-name:"id/name" description:"Only show this image and every image built on it, with the image as the root."`
+	FromCompose  string        `long:"from-compose" value-name:"FILE" description:"Only show the images used by the services of a compose file, and their ancestors."`
+	Include      []string      `long:"include" value-name:"PATTERN" description:"Only show images whose tag matches the glob or whose id starts with PATTERN, plus their ancestors. Repeatable."`
+	Exclude      []string      `long:"exclude" value-name:"PATTERN" description:"Hide images whose tag matches the glob or whose id starts with PATTERN, plus their descendants. Repeatable."`
+	FilterFile   string        `long:"repo-filter-file" value-name:"FILE" description:"Read --include patterns from FILE, one per line, with ! marking an --exclude pattern and # a comment."`
+	DotAttrs     []string      `long:"dot-attr" value-name:"key=value" description:"Add a graph attribute to dot output (e.g. bgcolor=white). Repeatable."`
+	WarnTags     int           `long:"warn-tags-over" value-name:"N" description:"Warn on stderr about repositories with more than N tags; an error with --strict."`
+	OrphanCount  bool          `long:"orphan-summary" description:"Report on stderr how many images, and how much space, have a parent missing from the input."`
+	Depth        int           `long:"depth" value-name:"N" description:"In tree output, show at most N levels below each root."`
+	DepthByRoot  string        `long:"depth-per-root" value-name:"ref=N,..." description:"In tree output, override --depth for the roots of the named images, e.g. nginx=2,ubuntu=5."`
+	Percent      bool          `long:"percent" description:"In tree output, follow each size with the share the image's own layers take of the total size of the images shown, so the shares add up to 100%."`
+	TaggedOnly   bool          `long:"tagged-only" description:"In tree and dot output, show only tagged images, linking each to its nearest tagged ancestor with the count and size of the layers between."`
+	SkipUntagged bool          `long:"skip-untagged-roots" description:"Drop untagged root images from the output, showing their children as roots instead."`
+	HideOrphans  bool          `long:"hide-orphans" description:"Drop untagged, childless images whose parent is missing instead of showing them as roots."`
+	UseHistory   bool          `long:"use-history" description:"Rebuild the tree from image histories, for daemons that do not report parent ids (Docker 1.10+)."`
+	InferParents bool          `long:"infer-parents" description:"When no image has a parent id (Docker 1.10+), guess the lineage from histories or from repository names."`
+	AllLayers    bool          `long:"all-layers" description:"Show untagged intermediate layers in tree and dot output (hidden by default)."`
+	Interactive  bool          `long:"interactive" description:"Browse the image tree in an interactive terminal UI."`
 }
 
 var imagesCommand ImagesCommand
 
-func (x *ImagesCommand) Execute(args []string) error {
+func (x *ImagesCommand) Execute(args []string) (err error) {
 	var images *[]Image
 
-	stat, err := os.Stdin.Stat()
-	if err != nil {
-		return fmt.Errorf("error reading stdin stat", err)
+	if imagesCommand.PrintSchema {
+		schema, err := jsonSchema(imagesCommand.NDJSON, jsonStyle{SnakeCase: imagesCommand.JSONKeys == "snake", OmitEmpty: imagesCommand.OmitEmpty})
+		if err != nil {
+			return err
+		}
+		fmt.Print(schema)
+		return nil
 	}
 
-	if (stat.Mode() & os.ModeCharDevice) == 0 {
-		// read in stdin
-		stdin, err := ioutil.ReadAll(os.Stdin)
-		if err != nil {
-			return fmt.Errorf("error reading all input", err)
+	var budget int64
+	if len(imagesCommand.FailOver) > 0 {
+		if budget, err = parseSize(imagesCommand.FailOver); err != nil {
+			return err
+		}
+		if budget <= 0 {
+			return fmt.Errorf("--fail-over needs a size above zero, e.g. 500MB")
 		}
+	}
 
-		images, err = parseImagesJSON(stdin)
+	// without an explicit format, the output file's extension picks one
+	showDot, showJSON, render := imagesCommand.Dot, imagesCommand.JSON, imagesCommand.Render
+	if len(imagesCommand.Output) > 0 && !outputModeSelected(imagesCommand) {
+		format, err := outputFormat(imagesCommand.Output)
 		if err != nil {
 			return err
 		}
+		switch format {
+		case "dot":
+			showDot = true
+		case "json":
+			showJSON = true
+		default:
+			render = format
+		}
+	}
+
+	if images, err = stdinImages(); err != nil {
+		return err
+	}
+
+	if images != nil {
+		if imagesCommand.Verify {
+			client, err := connect()
+			if err != nil {
+				return err
+			}
+			report, err := verifyImages(client, *images, imagesCommand.SizeFormat, imagesCommand.TruncLength)
+			fmt.Print(report)
+			return err
+		}
+		if imagesCommand.NewerThan > 0 {
+			*images = newerThan(*images, time.Now(), imagesCommand.NewerThan)
+		}
 
 	} else {
 
-		client, err := connect()
+		if imagesCommand.Verify {
+			return fmt.Errorf("--verify compares saved image JSON with the daemon, please pipe it in on stdin")
+		}
+
+		client, err := connectDaemon()
 		if err != nil {
 			return err
 		}
 
-		clientImages, err := client.ListImages(docker.ListImagesOptions{All: true})
+		var gated []string
+		if imagesCommand.Digests {
+			gated = append(gated, "--digests")
+		}
+		if imagesCommand.MergeDigests {
+			gated = append(gated, "--merge-by-digest")
+		}
+		if imagesCommand.History {
+			gated = append(gated, "--history")
+		}
+		if len(gated) > 0 {
+			probeDaemon(client)
+			if err := checkFeatures(daemon.APIVersion, gated, os.Stderr, globalOptions.Strict); err != nil {
+				return err
+			}
+		}
+
+		ims, err := daemonImages(client, imagesCommand.Digests || imagesCommand.MergeDigests, "images")
 		if err != nil {
-			if in_docker := os.Getenv("IN_DOCKER"); len(in_docker) > 0 {
-				return fmt.Errorf("Unable to access Docker socket, please run like this:\n  docker run --rm -v /var/run/docker.sock:/var/run/docker.sock nate/dockviz images <args>\nFor more help, run 'dockviz help'")
+			return err
+		}
+
+		// the images API only filters relative to other images, not by time,
+		// so recent images are picked out here (before any enrichment)
+		if imagesCommand.NewerThan > 0 {
+			ims = newerThan(ims, time.Now(), imagesCommand.NewerThan)
+		}
+
+		if imagesCommand.History || imagesCommand.UseHistory || imagesCommand.SharedLayers || imagesCommand.Savings {
+			fetching := startProgress(progressWriter(os.Stderr, globalOptions.Quiet), "Fetching image histories", len(ims))
+			histories := timeoutHistoryClient{client, globalOptions.Timeout}
+			// warnings wait for the progress line to be cleared
+			var warnings bytes.Buffer
+			err := fetchHistories(progressHistoryClient{histories, fetching}, ims, imagesCommand.Concurrency, distinctLength(ims, imagesCommand.TruncLength), &warnings, globalOptions.Strict)
+			fetching.done()
+			os.Stderr.Write(warnings.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+		// containers keep their image from being removed
+		if imagesCommand.Usage || imagesCommand.Reclaimable || imagesCommand.PrunePlan || len(imagesCommand.Prometheus) > 0 {
+			containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+			if err != nil && globalOptions.Strict {
+				return err
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: unable to list containers, usage is not shown: %s\n", err)
 			} else {
-				return fmt.Errorf("Unable to connect: %s\nFor help, run 'dockviz help'", err)
+				countContainers(ims, containers)
 			}
 		}
 
-		var ims []Image
-		for _, image := range clientImages {
-			// fmt.Println(image)
-			ims = append(ims, Image{
-				image.ID,
-				image.ParentID,
-				image.RepoTags,
-				image.VirtualSize,
-				image.Size,
-				image.Created,
-			})
+		images = &ims
+	}
+
+	if imagesCommand.UseHistory {
+		*images = treeFromHistory(*images)
+	}
+	if imagesCommand.InferParents {
+		inferParents(*images)
+	}
+	if imagesCommand.MergeDigests {
+		*images = mergeByDigest(*images)
+	}
+	if imagesCommand.OrphanCount {
+		fmt.Fprint(os.Stderr, orphanSummary(*images, imagesCommand.SizeFormat))
+	}
+	*images = promoteOrphans(*images, imagesCommand.HideOrphans)
+
+	if err := checkDuplicateTags(*images, distinctLength(*images, imagesCommand.TruncLength), os.Stderr, globalOptions.Strict); err != nil {
+		return err
+	}
+
+	if imagesCommand.WarnTags > 0 {
+		if err := checkTagCounts(*images, imagesCommand.WarnTags, os.Stderr, globalOptions.Strict); err != nil {
+			return err
+		}
+	}
+
+	if len(imagesCommand.FromCompose) > 0 {
+		ids, err := composeImageIds(imagesCommand.FromCompose, *images, os.Stderr)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			// no include patterns would select every image
+			*images = nil
+		} else {
+			*images = selectImages(*images, ids, nil)
 		}
+	}
 
-		images = &ims
+	if len(imagesCommand.AncestorOf) > 0 {
+		startImage, err := findStartImage(imagesCommand.AncestorOf, images)
+		if err != nil {
+			return err
+		}
+		*images = ancestorsOf(*images, startImage.Id)
 	}
 
-	if imagesCommand.Tree || imagesCommand.Dot {
+	if len(imagesCommand.DescendantOf) > 0 {
+		startImage, err := findStartImage(imagesCommand.DescendantOf, images)
+		if err != nil {
+			return err
+		}
+		*images = descendantsOf(*images, startImage.Id)
+	}
+
+	includes, excludes := imagesCommand.Include, imagesCommand.Exclude
+	if len(imagesCommand.FilterFile) > 0 {
+		fileIncludes, fileExcludes, err := readFilterFile(imagesCommand.FilterFile)
+		if err != nil {
+			return err
+		}
+		includes = append(append([]string{}, includes...), fileIncludes...)
+		excludes = append(append([]string{}, excludes...), fileExcludes...)
+	}
+
+	if len(includes) > 0 || len(excludes) > 0 {
+		*images = selectImages(*images, includes, excludes)
+	}
+
+	if imagesCommand.IgnoreEmpty {
+		*images = dropEmpty(*images)
+	}
+
+	if imagesCommand.HasLabels {
+		*images = withLabels(*images)
+	}
+
+	// names given on the command line are the daemon's, not the renamed ones
+	var unrenamed []Image
+	if len(imagesCommand.Rename) > 0 {
+		renames, err := parseRenames(imagesCommand.Rename)
+		if err != nil {
+			return err
+		}
+		unrenamed = *images
+		*images = renameRepos(*images, renames)
+	}
+
+	// a count of zero is still an answer
+	if imagesCommand.Count {
+		fmt.Println(len(*images))
+		return nil
+	}
+
+	if empty, err := checkNoImages(*images, os.Stderr, globalOptions.Strict); empty {
+		return err
+	}
+
+	idLength, err := checkTruncation(*images, imagesCommand.TruncLength, os.Stderr, globalOptions.Strict)
+	if err != nil {
+		return err
+	}
+
+	// check the size budget once the output is done
+	if budget > 0 {
+		selected := *images
+		defer func() {
+			if err == nil {
+				err = checkSizeBudget(selected, budget, imagesCommand.SizeFormat, idLength)
+			}
+		}()
+	}
+
+	if imagesCommand.SummaryOnly {
+		fmt.Print(summaryLine(*images, imagesCommand.SizeFormat, idLength))
+		return nil
+	}
+
+	if imagesCommand.Tree || showDot || len(render) > 0 || len(imagesCommand.SplitByRepo) > 0 || showJSON || imagesCommand.NDJSON || imagesCommand.Interactive {
+		// measured on all layers, before intermediate ones are hidden
+		var savings string
+		if imagesCommand.Savings {
+			savings = sharedSavings(*images, imagesCommand.SizeFormat)
+		}
+
 		var startImage *Image
 		if len(args) > 0 {
-			startImage, err = findStartImage(args[0], images)
+			startImage, err = findNamedImage(args[0], images, unrenamed)
 
 			if err != nil {
 				return err
 			}
 		}
 
-		// select the start image of the tree
+		treeOptions := TreeOptions{
+			NoTruncate:  imagesCommand.NoTruncate,
+			IdLength:    idLength,
+			Incremental: imagesCommand.Incremental,
+			SizeFormat:  imagesCommand.SizeFormat,
+			NoSizeLabel: imagesCommand.NoSizeLabel,
+			ShowHistory: imagesCommand.History,
+			ShowUsage:   imagesCommand.Usage,
+			ShowDigests: imagesCommand.Digests,
+			MaxWidth:    imagesCommand.MaxWidth,
+			IndentStyle: imagesCommand.IndentStyle,
+			Depth:       imagesCommand.Depth,
+			JSONStyle:   jsonStyle{SnakeCase: imagesCommand.JSONKeys == "snake", OmitEmpty: imagesCommand.OmitEmpty},
+		}
+		if imagesCommand.RecentSince > 0 {
+			treeOptions.RecentSince = time.Now().Add(-imagesCommand.RecentSince)
+			// escapes would end up in redirected output
+			treeOptions.Plain = !isTerminal(int(os.Stdout.Fd()))
+		}
+		if treeOptions.MaxWidth == 0 {
+			treeOptions.MaxWidth = terminalWidth()
+		}
+
+		// count layers before any reparenting hides intermediate ones
+		if imagesCommand.LayerCount {
+			treeOptions.LayerCounts = layerCounts(*images)
+		}
+
 		var roots []Image
-		if startImage == nil {
-			roots = collectRoots(images)
+		var imagesByParent map[string][]Image
+		var added []Image
+		if len(imagesCommand.SinceImage) > 0 {
+			if startImage == nil {
+				return fmt.Errorf("--since-image needs an image to compare against it, e.g. -t --since-image <base> <image>")
+			}
+			baseImage, err := findNamedImage(imagesCommand.SinceImage, images, unrenamed)
+			if err != nil {
+				return err
+			}
+			added, err = layersSince(baseImage, startImage, *images, idLength)
+			if err != nil {
+				return err
+			}
+
+			// show every added layer, sized by what it adds
+			roots, imagesByParent = chainToTree(added)
+			treeOptions.Incremental = true
 		} else {
-			startImage.ParentId = ""
-			roots = []Image{*startImage}
+			allLayers := (imagesCommand.AllLayers || imagesCommand.FoldUntagged || imagesCommand.TaggedOnly || imagesCommand.MarkDangling || imagesCommand.Reclaimable) && !imagesCommand.OnlyLabelled
+			roots, imagesByParent = prepareTree(images, startImage, allLayers)
+		}
+
+		var skipped map[string]string
+		if imagesCommand.TaggedOnly {
+			roots, imagesByParent, skipped = collapseToTagged(flattenTree(roots, imagesByParent), treeOptions.SizeFormat)
+			treeOptions.Skipped = skipped
+		}
+
+		if imagesCommand.SkipUntagged {
+			roots = skipUntaggedRoots(roots, imagesByParent)
+		}
+
+		// taken over the images shown, so their own sizes add up to 100%
+		if imagesCommand.Percent {
+			treeOptions.PercentOf = computeStats(flattenTree(roots, imagesByParent)).TotalIncrementalSize
+		}
+
+		// only the images shown are marked and totalled
+		var reclaimable []Image
+		if imagesCommand.Reclaimable {
+			reclaimable = reclaimableImages(flattenTree(roots, imagesByParent))
+			treeOptions.Reclaimable = make(map[string]bool)
+			for _, image := range reclaimable {
+				treeOptions.Reclaimable[image.Id] = true
+			}
 		}
 
-		// build helper map (image -> children)
-		imagesByParent := collectChildren(images)
+		if len(imagesCommand.DepthByRoot) > 0 {
+			if treeOptions.RootDepths, err = parseRootDepths(imagesCommand.DepthByRoot, roots, imagesByParent); err != nil {
+				return err
+			}
+		}
 
-		// filter images
-		if imagesCommand.OnlyLabelled {
-			*images, imagesByParent = filterImages(images, &imagesByParent)
+		if imagesCommand.LatestOnly {
+			relabelTree(roots, imagesByParent, latestTags(flattenTree(roots, imagesByParent)))
+		}
+		if imagesCommand.ShortNames {
+			relabelTree(roots, imagesByParent, shortTags(flattenTree(roots, imagesByParent), imagesCommand.NoNamespace))
 		}
 
+		if imagesCommand.Interactive {
+			return browseTree(roots, imagesByParent, treeOptions)
+		}
+		if showJSON {
+			if len(imagesCommand.Output) > 0 {
+				var buffer bytes.Buffer
+				if err := writeTreeJSON(&buffer, roots, imagesByParent, treeOptions, imagesCommand.FoldUntagged); err != nil {
+					return err
+				}
+				if imagesCommand.DryRun {
+					dryRunOutput(os.Stderr, buffer.Bytes(), "json", imagesCommand.Output)
+					return nil
+				}
+				return writeOutput(buffer.Bytes(), imagesCommand.Output)
+			}
+			return writeTreeJSON(os.Stdout, roots, imagesByParent, treeOptions, imagesCommand.FoldUntagged)
+		}
+		if imagesCommand.NDJSON {
+			return writeNDJSON(os.Stdout, roots, imagesByParent, treeOptions)
+		}
 		if imagesCommand.Tree {
-			fmt.Print(jsonToTree(roots, imagesByParent, imagesCommand.NoTruncate, imagesCommand.Incremental))
+			fmt.Print(jsonToTree(roots, imagesByParent, treeOptions))
+			if len(imagesCommand.SinceImage) > 0 {
+				fmt.Print(sinceSummary(added, treeOptions.SizeFormat))
+			}
+			if imagesCommand.Reclaimable {
+				fmt.Print(reclaimableSummary(reclaimable, treeOptions.SizeFormat))
+			}
+			if imagesCommand.RepoTotals {
+				fmt.Print("\n" + repoTotals(*images, treeOptions.SizeFormat))
+			}
+			fmt.Print(savings)
 		}
-		if imagesCommand.Dot {
-			fmt.Print(jsonToDot(roots, imagesByParent))
+		if showDot || len(render) > 0 || len(imagesCommand.SplitByRepo) > 0 {
+			graphAttrs, err := parseDotAttrs(imagesCommand.DotAttrs)
+			if err != nil {
+				return err
+			}
+			if !dotAttrKey.MatchString(imagesCommand.BaseName) {
+				return fmt.Errorf("Invalid --base-name '%s', expected letters, digits and underscores.", imagesCommand.BaseName)
+			}
+			if imagesCommand.Legend && !imagesCommand.ColorByAge {
+				return fmt.Errorf("--legend describes the colors of --color-by-age, please use them together")
+			}
+			dotOptions := DotOptions{
+				ColorByAge:   imagesCommand.ColorByAge,
+				Scheme:       imagesCommand.ColorScheme,
+				Legend:       imagesCommand.Legend,
+				RecentSince:  treeOptions.RecentSince,
+				BaseName:     imagesCommand.BaseName,
+				AllNodes:     imagesCommand.AllNodes,
+				TagNodes:     imagesCommand.TagNodes,
+				MarkDangling: imagesCommand.MarkDangling,
+				LabelsOnly:   imagesCommand.LabelsOnly,
+				GraphAttrs:   graphAttrs,
+				Title:        imagesCommand.Title,
+				Concentrate:  imagesCommand.Concentrate,
+				FullIdTitle:  imagesCommand.FullIdTitle,
+				LabelShape:   imagesCommand.LabelShape,
+				Skipped:      skipped,
+				IdLength:     idLength,
+			}
+			if dotOptions.Title == "auto" {
+				dotOptions.Title = autoTitle(daemon.Endpoint, time.Now())
+			}
+			if len(imagesCommand.SplitByRepo) > 0 {
+				if imagesCommand.DryRun {
+					paths, dots := repoDotFiles(flattenTree(roots, imagesByParent), imagesCommand.SplitByRepo, dotOptions)
+					for index, path := range paths {
+						dryRunOutput(os.Stderr, []byte(dots[index]), "dot", path)
+					}
+					return nil
+				}
+				written, err := splitByRepo(flattenTree(roots, imagesByParent), imagesCommand.SplitByRepo, dotOptions)
+				if err != nil {
+					return err
+				}
+				for _, path := range written {
+					fmt.Println(path)
+				}
+				fmt.Printf("Wrote %d dot files to %s\n", len(written), imagesCommand.SplitByRepo)
+				return nil
+			}
+			if imagesCommand.MaxNodes > 0 {
+				roots, imagesByParent, dotOptions.NotShown = capNodes(roots, imagesByParent, imagesCommand.MaxNodes)
+			}
+			var dot string
+			if imagesCommand.ReposOnly {
+				dot = reposToDot(flattenTree(roots, imagesByParent), dotOptions)
+			} else {
+				dot = jsonToDot(roots, imagesByParent, dotOptions)
+			}
+			if len(render) > 0 {
+				if imagesCommand.DryRun {
+					dryRunRendered(os.Stderr, dot, render, imagesCommand.Output)
+					return nil
+				}
+				return writeRendered(dot, render, imagesCommand.Output)
+			}
+			if len(imagesCommand.Output) > 0 {
+				if imagesCommand.DryRun {
+					dryRunOutput(os.Stderr, []byte(dot), "dot", imagesCommand.Output)
+					return nil
+				}
+				return writeOutput([]byte(dot), imagesCommand.Output)
+			}
+			fmt.Print(dot)
 		}
 
 	} else if imagesCommand.Short {
-		fmt.Printf(jsonToShort(images))
+		shown := images
+		if imagesCommand.ShortNames {
+			shown = relabelImages(*images, shortTags(*images, imagesCommand.NoNamespace))
+		}
+		fmt.Print(jsonToShort(shown, ShortOptions{
+			WithDepth:  imagesCommand.WithDepth,
+			WithAge:    imagesCommand.WithAge,
+			Expand:     imagesCommand.Expand,
+			Flatten:    imagesCommand.Flatten,
+			Oneline:    imagesCommand.Oneline,
+			LatestLast: imagesCommand.LatestLast,
+			Align:      imagesCommand.Align,
+			MaxAlign:   imagesCommand.MaxAlign,
+			SortBy:     imagesCommand.SortReposBy,
+			Reverse:    imagesCommand.Reverse,
+			Tree: TreeOptions{
+				NoTruncate:  imagesCommand.NoTruncate,
+				IdLength:    idLength,
+				SizeFormat:  imagesCommand.SizeFormat,
+				NoSizeLabel: imagesCommand.NoSizeLabel,
+				IndentStyle: imagesCommand.IndentStyle,
+			},
+		}))
+	} else if imagesCommand.AgeHistogram {
+		buckets := defaultAgeBuckets()
+		if len(imagesCommand.AgeBuckets) > 0 {
+			buckets, err = parseAgeBuckets(imagesCommand.AgeBuckets)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Print(ageHistogram(*images, time.Now(), buckets, imagesCommand.SizeFormat))
+	} else if imagesCommand.Treemap {
+		colorScheme := lookupPalette(imagesCommand.ColorScheme)
+		shown := *images
+		if imagesCommand.ShortNames {
+			shown = *relabelImages(shown, shortTags(shown, imagesCommand.NoNamespace))
+		}
+		treemap := []byte(imagesToTreemap(shown, imagesCommand.Incremental, imagesCommand.SizeFormat, colorScheme, idLength))
+		if imagesCommand.DryRun {
+			dryRunOutput(os.Stderr, treemap, "svg", imagesCommand.Output)
+			return nil
+		}
+		return writeOutput(treemap, imagesCommand.Output)
+	} else if imagesCommand.Timeline {
+		zone, err := time.LoadLocation(imagesCommand.TimeZone)
+		if err != nil {
+			return fmt.Errorf("Invalid --tz '%s': %s", imagesCommand.TimeZone, err)
+		}
+		fmt.Print(timeline(*images, zone))
+	} else if len(imagesCommand.Prometheus) > 0 {
+		output := imagesCommand.Prometheus
+		if output == "-" {
+			output = ""
+		}
+		metrics := []byte(prometheusMetrics(*images))
+		if imagesCommand.DryRun {
+			dryRunOutput(os.Stderr, metrics, "prometheus metrics", output)
+			return nil
+		}
+		return writeOutput(metrics, output)
+	} else if imagesCommand.StatsJSON {
+		stats, err := statsJSON(*images, jsonStyle{SnakeCase: imagesCommand.JSONKeys == "snake", OmitEmpty: imagesCommand.OmitEmpty})
+		if err != nil {
+			return err
+		}
+		fmt.Print(stats)
+	} else if imagesCommand.OnlyRoots {
+		fmt.Print(listRoots(*images, TreeOptions{
+			NoTruncate:  imagesCommand.NoTruncate,
+			IdLength:    idLength,
+			SizeFormat:  imagesCommand.SizeFormat,
+			NoSizeLabel: imagesCommand.NoSizeLabel,
+		}, imagesCommand.SortReposBy, imagesCommand.Reverse))
+	} else if imagesCommand.SharedLayers {
+		fmt.Print(duplicateLayers(*images, imagesCommand.SizeFormat))
+	} else if len(imagesCommand.Registry) > 0 {
+		if len(args) != 1 {
+			return fmt.Errorf("--registry needs the repository to look up, e.g. --registry <url> <repo>")
+		}
+		tags, err := registryTags(&http.Client{Timeout: globalOptions.Timeout}, imagesCommand.Registry, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(registryStatus(tags, *images, imagesCommand.Registry, args[0], idLength))
+	} else if imagesCommand.RepoDiff {
+		if len(args) != 2 {
+			return fmt.Errorf("--repo-diff needs two repositories to compare, e.g. --repo-diff <repo> <repo>")
+		}
+		fmt.Print(repoDiff(*images, args[0], args[1], idLength))
+	} else if imagesCommand.GroupByBase {
+		fmt.Print(groupByBase(*images, imagesCommand.SizeFormat, imagesCommand.SortReposBy, idLength))
+	} else if imagesCommand.PrunePlan {
+		fmt.Print(prunePlan(*images, imagesCommand.SizeFormat))
+	} else if len(imagesCommand.CountPrefix) > 0 {
+		fmt.Print(countByPrefix(*images, imagesCommand.CountPrefix, imagesCommand.SizeFormat))
 	} else {
 		return fmt.Errorf("Please specify either --dot, --tree, or --short")
 	}
@@ -126,6 +709,171 @@ func (x *ImagesCommand) Execute(args []string) error {
 	return nil
 }
 
+var errNoImages = errors.New("no images to display")
+
+// inferParents guesses a lineage for images when none of them has a parent
+// id, as is the case for images pulled on Docker 1.10 and later.  An image
+// whose history mentions another image is placed under the most recent such
+// image.  Otherwise, the images of a repository are chained from oldest to
+// newest.  Inferred parents are flagged so they can be marked as such.
+func inferParents(images []Image) bool {
+	for _, image := range images {
+		if len(image.ParentId) > 0 {
+			return false
+		}
+	}
+
+	byId := make(map[string]bool)
+	for _, image := range images {
+		byId[image.Id] = true
+	}
+
+	byRepo := make(map[string][]int)
+	for index, image := range images {
+		for _, layer := range image.History {
+			if layer.Id != image.Id && byId[layer.Id] {
+				images[index].ParentId = layer.Id
+				break
+			}
+		}
+		if len(images[index].ParentId) > 0 || !isTagged(image) {
+			continue
+		}
+
+		reponame, _ := splitRepoTag(image.RepoTags[0])
+		byRepo[reponame] = append(byRepo[reponame], index)
+	}
+
+	for _, indexes := range byRepo {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := images[indexes[i]], images[indexes[j]]
+			if a.Created != b.Created {
+				return a.Created < b.Created
+			}
+			return a.Id < b.Id
+		})
+		for i := 1; i < len(indexes); i++ {
+			images[indexes[i]].ParentId = images[indexes[i-1]].Id
+		}
+	}
+
+	inferred := false
+	for index := range images {
+		if len(images[index].ParentId) > 0 {
+			images[index].InferredParent = true
+			inferred = true
+		}
+	}
+
+	return inferred
+}
+
+// checkNoImages reports whether there is nothing to display, telling the user
+// so on warnings.  In strict mode an empty set is an error instead, which
+// exits with a distinct status.
+func checkNoImages(images []Image, warnings io.Writer, strict bool) (bool, error) {
+	if len(images) > 0 {
+		return false, nil
+	}
+	if strict {
+		return true, errNoImages
+	}
+	fmt.Fprintln(warnings, errNoImages)
+
+	return true, nil
+}
+
+// checkDuplicateTags warns about tags that point at more than one image id,
+// which only happens with stale or hand-assembled data.  In strict mode the
+// warnings turn into an error.
+func checkDuplicateTags(images []Image, idLength int, warnings io.Writer, strict bool) error {
+	idsByTag := make(map[string][]string)
+	for _, image := range images {
+		for _, repotag := range image.RepoTags {
+			if repotag != "<none>:<none>" {
+				idsByTag[repotag] = append(idsByTag[repotag], image.Id)
+			}
+		}
+	}
+
+	var duplicates []string
+	for repotag, ids := range idsByTag {
+		if len(ids) > 1 {
+			duplicates = append(duplicates, repotag)
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	sort.Strings(duplicates)
+
+	for _, repotag := range duplicates {
+		var ids []string
+		for _, id := range idsByTag[repotag] {
+			ids = append(ids, truncateTo(id, idLength))
+		}
+		fmt.Fprintf(warnings, "Warning: tag %s points at multiple images: %s\n", repotag, strings.Join(ids, ", "))
+	}
+	if strict {
+		return fmt.Errorf("Found %d tags pointing at multiple images.", len(duplicates))
+	}
+
+	return nil
+}
+
+// checkTagCounts warns about repositories carrying more than max tags, to
+// catch tags piling up unchecked.  In strict mode the warnings turn into an
+// error.
+func checkTagCounts(images []Image, max int, warnings io.Writer, strict bool) error {
+	byRepo := make(map[string]int)
+	for _, image := range images {
+		for _, repotag := range image.RepoTags {
+			if repotag != "<none>:<none>" {
+				reponame, _ := splitRepoTag(repotag)
+				byRepo[reponame]++
+			}
+		}
+	}
+
+	var crowded []string
+	for reponame, count := range byRepo {
+		if count > max {
+			crowded = append(crowded, reponame)
+		}
+	}
+	if len(crowded) == 0 {
+		return nil
+	}
+	sort.Strings(crowded)
+
+	for _, reponame := range crowded {
+		fmt.Fprintf(warnings, "Warning: repository %s has %d tags, more than %d\n", reponame, byRepo[reponame], max)
+	}
+	if strict {
+		return fmt.Errorf("Found %d repositories with more than %d tags.", len(crowded), max)
+	}
+
+	return nil
+}
+
+// fromAPIImages converts the daemon's image listing.
+func fromAPIImages(clientImages []docker.APIImages) []Image {
+	var ims []Image
+	for _, image := range clientImages {
+		ims = append(ims, Image{
+			Id:          image.ID,
+			ParentId:    image.ParentID,
+			RepoTags:    image.RepoTags,
+			VirtualSize: image.VirtualSize,
+			Size:        image.Size,
+			Created:     image.Created,
+			RepoDigests: image.RepoDigests,
+			Labels:      image.Labels,
+		})
+	}
+	return ims
+}
+
 func findStartImage(name string, images *[]Image) (*Image, error) {
 
 	var startImage *Image
@@ -158,39 +906,616 @@ IMAGES:
 	}
 
 	if startImage == nil {
-		return nil, fmt.Errorf("Unable to find image %s = %s.", startImageArg, startImageRepo)
+		return nil, newKindError(ErrImageNotFound, "Unable to find image %s = %s.", startImageArg, startImageRepo)
 	}
 
 	return startImage, nil
 }
 
-func jsonToTree(images []Image, byParent map[string][]Image, noTrunc bool, incremental bool) string {
-	var buffer bytes.Buffer
+// findNamedImage is findStartImage for names that may use the tags the images
+// had before --rename, when unrenamed holds them.
+func findNamedImage(name string, images *[]Image, unrenamed []Image) (*Image, error) {
+	if original, err := findStartImage(name, &unrenamed); err == nil {
+		return findStartImage(original.Id, images)
+	}
+	return findStartImage(name, images)
+}
 
-	jsonToText(&buffer, images, byParent, noTrunc, incremental, "")
+type TreeOptions struct {
+	NoTruncate  bool
+	Incremental bool
+	SizeFormat  string
+	NoSizeLabel bool
+	ShowHistory bool
+	ShowUsage   bool
+	ShowDigests bool
+	LayerCounts map[string]int
+	Reclaimable map[string]bool
 
-	return buffer.String()
-}
+	// wrap tag lists onto continuation lines past this width (0 for never)
+	MaxWidth int
 
-func jsonToDot(roots []Image, byParent map[string][]Image) string {
-	var buffer bytes.Buffer
+	// one of the indentStyles, unicode when empty
+	IndentStyle string
 
-	buffer.WriteString("digraph docker {\n")
-	imagesToDot(&buffer, roots, byParent)
-	buffer.WriteString(" base [style=invisible]\n}\n")
+	// dim images created before this time and highlight newer ones, with
+	// terminal escapes that are left out when Plain is set
+	RecentSince time.Time
+	Plain       bool
 
-	return buffer.String()
-}
+	// key casing and empty values of JSON output
+	JSONStyle jsonStyle
 
-func collectChildren(images *[]Image) map[string][]Image {
-	var imagesByParent = make(map[string][]Image)
-	for _, image := range *images {
-		if children, exists := imagesByParent[image.ParentId]; exists {
-			imagesByParent[image.ParentId] = append(children, image)
-		} else {
-			imagesByParent[image.ParentId] = []Image{image}
-		}
-	}
+	// containers listed under the image they run, by image id
+	Containers map[string][]Container
+
+	// show each image's own size as a share of this total as well, when set
+	PercentOf int64
+
+	// the layers --tagged-only left out above each image, by id
+	Skipped map[string]string
+
+	// characters of image ids shown, defaultTruncLength when 0
+	IdLength int
+
+	// levels shown below each root (0 for all), overridden per root id
+	Depth      int
+	RootDepths map[string]int
+
+	// level of the images being printed, and the limit of their root
+	level      int
+	depthLimit int
+}
+
+// descend returns the options for the children of image, and whether they
+// are within the depth limit of its root.
+func (opts TreeOptions) descend(image Image) (TreeOptions, bool) {
+	if opts.level == 0 {
+		opts.depthLimit = opts.Depth
+		if limit, exists := opts.RootDepths[image.Id]; exists {
+			opts.depthLimit = limit
+		}
+	}
+	opts.level++
+
+	return opts, opts.depthLimit == 0 || opts.level <= opts.depthLimit
+}
+
+func jsonToTree(images []Image, byParent map[string][]Image, opts TreeOptions) string {
+	var buffer bytes.Buffer
+
+	jsonToText(&buffer, images, byParent, opts, "")
+
+	return buffer.String()
+}
+
+type DotOptions struct {
+	ColorByAge   bool
+	Legend       bool
+	RecentSince  time.Time
+	AllNodes     bool
+	TagNodes     bool
+	MarkDangling bool
+	LabelsOnly   bool
+	GraphAttrs   []dotAttr
+	Title        string
+	Concentrate  bool
+	FullIdTitle  bool
+	LabelShape   string
+
+	// edge labels by child id, for the layers --tagged-only left out
+	Skipped map[string]string
+
+	// id of the invisible node the roots hang from, "base" when empty
+	BaseName string
+
+	// name of the --color-scheme palette, the default one when empty
+	Scheme string
+
+	// number of images left out by --max-nodes, noted in the graph
+	NotShown int
+
+	// characters of image ids shown, defaultTruncLength when 0
+	IdLength int
+}
+
+// id truncates an image id to the length of the graph's ids.
+func (opts DotOptions) id(id string) string {
+	return truncateTo(id, opts.IdLength)
+}
+
+func (opts DotOptions) palette() palette {
+	return lookupPalette(opts.Scheme)
+}
+
+func (opts DotOptions) base() string {
+	if len(opts.BaseName) > 0 {
+		return opts.BaseName
+	}
+	return "base"
+}
+
+type dotAttr struct {
+	key   string
+	value string
+}
+
+var dotAttrKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseDotAttrs validates graph attributes given as key=value pairs.
+func parseDotAttrs(attrs []string) ([]dotAttr, error) {
+	var parsed []dotAttr
+	for _, attr := range attrs {
+		parts := strings.SplitN(attr, "=", 2)
+		if len(parts) != 2 || !dotAttrKey.MatchString(parts[0]) {
+			return nil, fmt.Errorf("Invalid dot attribute '%s', expected key=value.", attr)
+		}
+		parsed = append(parsed, dotAttr{parts[0], parts[1]})
+	}
+
+	return parsed, nil
+}
+
+func jsonToDot(roots []Image, byParent map[string][]Image, opts DotOptions) string {
+	var buffer bytes.Buffer
+
+	// per-node fill colors, overriding the default styling
+	var colors map[string]string
+	if !opts.RecentSince.IsZero() {
+		colors = recentColors(flattenTree(roots, byParent), opts.RecentSince)
+	} else if opts.ColorByAge {
+		colors = ageColors(flattenTree(roots, byParent), opts.palette())
+	}
+
+	writeDotHeader(&buffer, opts)
+	imagesToDot(&buffer, roots, byParent, colors, opts)
+	if opts.NotShown > 0 {
+		images := "images"
+		if opts.NotShown == 1 {
+			images = "image"
+		}
+		buffer.WriteString(fmt.Sprintf(" not_shown [label=\"(+%d %s not shown)\",shape=note];\n", opts.NotShown, images))
+	}
+	if opts.ColorByAge && opts.Legend {
+		ageLegend(&buffer, flattenTree(roots, byParent), opts.palette())
+	}
+	buffer.WriteString(fmt.Sprintf(" %s [style=invisible]\n}\n", opts.base()))
+
+	return buffer.String()
+}
+
+// writeDotHeader opens the graph and sets its attributes and title.
+func writeDotHeader(buffer *bytes.Buffer, opts DotOptions) {
+	buffer.WriteString("digraph docker {\n")
+	if opts.Concentrate {
+		buffer.WriteString(" concentrate=true\n")
+	}
+	for _, attr := range opts.GraphAttrs {
+		buffer.WriteString(fmt.Sprintf(" %s=\"%s\"\n", attr.key, strings.Replace(attr.value, "\"", "\\\"", -1)))
+	}
+	if len(opts.Title) > 0 {
+		buffer.WriteString(fmt.Sprintf(" label=\"%s\"\n labelloc=\"t\"\n", dotEscape(opts.Title)))
+	}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotEscape quotes text for use inside a double-quoted dot string.
+func dotEscape(text string) string {
+	return dotEscaper.Replace(text)
+}
+
+// recordEscaper additionally escapes the characters that split and name the
+// fields of a record label.
+var recordEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`, ` `, `\ `)
+
+// shape is the dot shape of labelled image nodes, box unless --label-shape
+// asks for records.
+func (opts DotOptions) shape() string {
+	if opts.LabelShape == "record" {
+		return "record"
+	}
+	return "box"
+}
+
+// nodeLabel escapes the lines of a node label and joins them, as stacked
+// fields for record shapes.
+func (opts DotOptions) nodeLabel(lines ...string) string {
+	escaped := make([]string, len(lines))
+	if opts.shape() == "record" {
+		for index, line := range lines {
+			escaped[index] = recordEscaper.Replace(line)
+		}
+		return "{" + strings.Join(escaped, "|") + "}"
+	}
+	for index, line := range lines {
+		escaped[index] = dotEscape(line)
+	}
+	return strings.Join(escaped, `\n`)
+}
+
+// autoTitle names the daemon's host, or this machine's when the daemon is
+// reached over a local socket (or not at all), and the date of the render.
+func autoTitle(endpoint string, now time.Time) string {
+	var host string
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Scheme != "unix" {
+		host = parsed.Hostname()
+	}
+	if len(host) == 0 {
+		host, _ = os.Hostname()
+	}
+
+	return fmt.Sprintf("Docker images on %s, %s", host, now.Format("2006-01-02 15:04"))
+}
+
+// outputModeSelected reports whether any flag choosing what to output is set.
+func outputModeSelected(cmd ImagesCommand) bool {
+	return cmd.Dot || cmd.Tree || cmd.Short || cmd.JSON || cmd.NDJSON || cmd.Interactive ||
+		cmd.AgeHistogram || cmd.Timeline || cmd.Treemap || cmd.StatsJSON || len(cmd.Prometheus) > 0 || cmd.OnlyRoots || cmd.SharedLayers ||
+		cmd.RepoDiff || len(cmd.Registry) > 0 || cmd.GroupByBase || cmd.PrunePlan || len(cmd.CountPrefix) > 0 || len(cmd.Render) > 0 || len(cmd.SplitByRepo) > 0 ||
+		cmd.SummaryOnly || cmd.Count || cmd.Verify
+}
+
+// prepareTree selects the roots of the tree and builds the image -> children
+// map.  Untagged intermediate layers are folded away unless allLayers is set.
+func prepareTree(images *[]Image, startImage *Image, allLayers bool) ([]Image, map[string][]Image) {
+
+	// select the start image of the tree
+	var roots []Image
+	if startImage == nil {
+		roots = collectRoots(images)
+	} else {
+		// the start image is a root now, so it stays visible even untagged
+		for i := range *images {
+			if (*images)[i].Id == startImage.Id {
+				(*images)[i].ParentId = ""
+			}
+		}
+		startImage.ParentId = ""
+		roots = []Image{*startImage}
+	}
+
+	// build helper map (image -> children)
+	imagesByParent := collectChildren(images)
+
+	// filter images
+	if !allLayers {
+		*images, imagesByParent = filterImages(images, &imagesByParent, false)
+	}
+
+	return roots, imagesByParent
+}
+
+// listRoots prints one line per root image, ordered by "name" (first tag,
+// then id), "tags" (tag count) or "size".
+func listRoots(images []Image, opts TreeOptions, sortBy string, reverse bool) string {
+	roots := collectRoots(&images)
+	var lineage map[string]int64
+	if sortBy == "lineage" {
+		lineage = descendantSizes(images)
+	}
+	name := func(image Image) string {
+		if isTagged(image) {
+			return image.RepoTags[0]
+		}
+		return image.Id
+	}
+	sort.SliceStable(roots, func(i, j int) bool {
+		a, b := roots[i], roots[j]
+		if reverse {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "tags":
+			if len(a.RepoTags) != len(b.RepoTags) {
+				return len(a.RepoTags) < len(b.RepoTags)
+			}
+		case "size":
+			if a.VirtualSize != b.VirtualSize {
+				return a.VirtualSize < b.VirtualSize
+			}
+		case "lineage":
+			// heaviest first
+			if lineage[a.Id] != lineage[b.Id] {
+				return lineage[a.Id] > lineage[b.Id]
+			}
+		}
+		if name(a) != name(b) {
+			return name(a) < name(b)
+		}
+		return a.Id < b.Id
+	})
+
+	var buffer bytes.Buffer
+	for _, root := range roots {
+		PrintTreeNode(&buffer, root, opts, "")
+	}
+
+	return buffer.String()
+}
+
+// descendantSizes totals, for every image, the own sizes of all the images
+// built on it, leaving out the image's own size.  Each subtree is only summed
+// once.
+func descendantSizes(images []Image) map[string]int64 {
+	byParent := collectChildren(&images)
+	sizes := make(map[string]int64)
+
+	var total func(image Image) int64
+	total = func(image Image) int64 {
+		if size, done := sizes[image.Id]; done {
+			return size
+		}
+		var size int64
+		for _, child := range byParent[image.Id] {
+			size += child.Size + total(child)
+		}
+		sizes[image.Id] = size
+		return size
+	}
+	for _, image := range images {
+		total(image)
+	}
+
+	return sizes
+}
+
+// flattenTree lists every image reachable from the roots, parents before
+// their children.
+func flattenTree(roots []Image, byParent map[string][]Image) []Image {
+	var images []Image
+	for _, image := range roots {
+		images = append(images, image)
+		if subimages, exists := byParent[image.Id]; exists {
+			images = append(images, flattenTree(subimages, byParent)...)
+		}
+	}
+
+	return images
+}
+
+// parseRootDepths resolves a list of ref=N pairs, with each ref naming an
+// image like findStartImage does, to depth limits keyed by the id of the root
+// the image sits under.
+func parseRootDepths(spec string, roots []Image, byParent map[string][]Image) (map[string]int, error) {
+	images := flattenTree(roots, byParent)
+	parents := make(map[string]string)
+	for parentId, children := range byParent {
+		for _, child := range children {
+			parents[child.Id] = parentId
+		}
+	}
+	isRoot := make(map[string]bool)
+	for _, root := range roots {
+		isRoot[root.Id] = true
+	}
+
+	depths := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid --depth-per-root entry '%s', expected ref=N.", pair)
+		}
+		depth, err := strconv.Atoi(parts[1])
+		if err != nil || depth < 1 {
+			return nil, fmt.Errorf("Invalid depth in --depth-per-root entry '%s', expected a positive number.", pair)
+		}
+		image, err := findStartImage(parts[0], &images)
+		if err != nil {
+			return nil, err
+		}
+
+		id := image.Id
+		for !isRoot[id] && len(parents[id]) > 0 {
+			id = parents[id]
+		}
+		depths[id] = depth
+	}
+
+	return depths, nil
+}
+
+// capNodes trims the tree to the max largest images, by virtual size, plus
+// the ancestors keeping them connected.  It returns the trimmed tree and how
+// many images were left out.
+func capNodes(roots []Image, byParent map[string][]Image, max int) ([]Image, map[string][]Image, int) {
+	images := flattenTree(roots, byParent)
+	if len(images) <= max {
+		return roots, byParent, 0
+	}
+
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+	largest := append([]Image{}, images...)
+	sort.SliceStable(largest, func(i, j int) bool {
+		if largest[i].VirtualSize != largest[j].VirtualSize {
+			return largest[i].VirtualSize > largest[j].VirtualSize
+		}
+		return largest[i].Id < largest[j].Id
+	})
+	kept := make(map[string]bool)
+	for _, image := range largest[:max] {
+		for id := image.Id; len(id) > 0 && !kept[id]; id = byId[id].ParentId {
+			if _, exists := byId[id]; !exists {
+				break
+			}
+			kept[id] = true
+		}
+	}
+
+	var cappedRoots []Image
+	cappedByParent := make(map[string][]Image)
+	for _, image := range images {
+		if !kept[image.Id] {
+			continue
+		}
+		if kept[image.ParentId] {
+			cappedByParent[image.ParentId] = append(cappedByParent[image.ParentId], image)
+		} else {
+			cappedRoots = append(cappedRoots, image)
+		}
+	}
+
+	return cappedRoots, cappedByParent, len(images) - len(kept)
+}
+
+type repoRename struct {
+	from, to string
+}
+
+func parseRenames(renames []string) ([]repoRename, error) {
+	var parsed []repoRename
+	for _, rename := range renames {
+		parts := strings.SplitN(rename, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("Invalid rename '%s', expected old=new.", rename)
+		}
+		parsed = append(parsed, repoRename{parts[0], parts[1]})
+	}
+	return parsed, nil
+}
+
+// renameRepos rewrites the start of repository names for display, using the
+// first rename whose prefix matches.  Tags are left as they are.
+func renameRepos(images []Image, renames []repoRename) []Image {
+	renamed := make([]Image, len(images))
+	for index, image := range images {
+		renamed[index] = image
+		if !isTagged(image) {
+			continue
+		}
+		renamed[index].RepoTags = make([]string, len(image.RepoTags))
+		for tagIndex, repotag := range image.RepoTags {
+			renamed[index].RepoTags[tagIndex] = repotag
+			repo, _ := splitRepoTag(repotag)
+			for _, rename := range renames {
+				if strings.HasPrefix(repo, rename.from) {
+					renamed[index].RepoTags[tagIndex] = rename.to + strings.TrimPrefix(repotag, rename.from)
+					break
+				}
+			}
+		}
+	}
+	return renamed
+}
+
+// relabelTree replaces the tags shown for each image in the tree with the
+// given ones.
+func relabelTree(roots []Image, byParent map[string][]Image, tags map[string][]string) {
+	for index := range roots {
+		if repoTags, exists := tags[roots[index].Id]; exists {
+			roots[index].RepoTags = repoTags
+		}
+		if subimages, exists := byParent[roots[index].Id]; exists {
+			relabelTree(subimages, byParent, tags)
+		}
+	}
+}
+
+// relabelImages copies the images, replacing the tags shown for each with
+// the given ones.
+func relabelImages(images []Image, tags map[string][]string) *[]Image {
+	relabeled := append([]Image{}, images...)
+	for index := range relabeled {
+		if repoTags, exists := tags[relabeled[index].Id]; exists {
+			relabeled[index].RepoTags = repoTags
+		}
+	}
+	return &relabeled
+}
+
+// shortTags drops the registry host from the tags of each tagged image, and
+// with dropNamespace everything up to the last slash.
+func shortTags(images []Image, dropNamespace bool) map[string][]string {
+	shown := make(map[string][]string)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		var short []string
+		for _, repotag := range image.RepoTags {
+			short = append(short, shortName(repotag, dropNamespace))
+		}
+		shown[image.Id] = short
+	}
+	return shown
+}
+
+// shortName strips the registry host, recognized like Docker does by a dot
+// or port in the first path component (or it being localhost), from a tag.
+func shortName(repotag string, dropNamespace bool) string {
+	reponame, tagname := splitRepoTag(repotag)
+	parts := strings.Split(reponame, "/")
+	if len(parts) > 1 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		parts = parts[1:]
+	}
+	if dropNamespace {
+		parts = parts[len(parts)-1:]
+	}
+
+	short := strings.Join(parts, "/")
+	if len(tagname) > 0 {
+		short += ":" + tagname
+	}
+	return short
+}
+
+// layersSince walks from the target image up to the base image, returning the
+// layers in between (oldest first, excluding the base itself).
+func layersSince(base *Image, target *Image, images []Image, idLength int) ([]Image, error) {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+
+	var added []Image
+	for id := target.Id; id != base.Id; id = byId[id].ParentId {
+		image, exists := byId[id]
+		if !exists {
+			return nil, fmt.Errorf("Image %s is not an ancestor of %s.", truncateTo(base.Id, idLength), truncateTo(target.Id, idLength))
+		}
+		added = append([]Image{image}, added...)
+	}
+
+	return added, nil
+}
+
+// chainToTree turns a list of layers (oldest first) into a single-branch tree.
+func chainToTree(chain []Image) ([]Image, map[string][]Image) {
+	if len(chain) == 0 {
+		return nil, map[string][]Image{}
+	}
+
+	layers := append([]Image{}, chain...)
+	layers[0].ParentId = ""
+
+	return []Image{layers[0]}, collectChildren(&layers)
+}
+
+func sinceSummary(added []Image, format string) string {
+	var total int64
+	for _, image := range added {
+		total += image.Size
+	}
+
+	layers := "layers"
+	if len(added) == 1 {
+		layers = "layer"
+	}
+	return fmt.Sprintf("%d %s added, %s in total\n", len(added), layers, formatSize(total, format))
+}
+
+func collectChildren(images *[]Image) map[string][]Image {
+	var imagesByParent = make(map[string][]Image)
+	for _, image := range *images {
+		if children, exists := imagesByParent[image.ParentId]; exists {
+			imagesByParent[image.ParentId] = append(children, image)
+		} else {
+			imagesByParent[image.ParentId] = []Image{image}
+		}
+	}
 
 	return imagesByParent
 }
@@ -206,13 +1531,200 @@ func collectRoots(images *[]Image) []Image {
 	return roots
 }
 
-func filterImages(images *[]Image, byParent *map[string][]Image) (filteredImages []Image, filteredChildren map[string][]Image) {
+// newerThan keeps the images created within maxAge of now.  Images without a
+// creation time are kept.
+func newerThan(images []Image, now time.Time, maxAge time.Duration) []Image {
+	cutoff := now.Add(-maxAge).Unix()
+
+	var recent []Image
+	for _, image := range images {
+		if image.Created == 0 || image.Created >= cutoff {
+			recent = append(recent, image)
+		}
+	}
+
+	return recent
+}
+
+// orphanSummary counts the images whose parent is missing from the set, and
+// totals their own size.
+func orphanSummary(images []Image, sizeFormat string) string {
+	present := make(map[string]bool)
+	for _, image := range images {
+		present[image.Id] = true
+	}
+
+	var count int
+	var total int64
+	for _, image := range images {
+		if len(image.ParentId) > 0 && !present[image.ParentId] {
+			count++
+			total += image.Size
+		}
+	}
+
+	return fmt.Sprintf("Orphans: %s in %d images whose parent is missing\n", formatSize(total, sizeFormat), count)
+}
+
+// mergeByDigest folds images sharing a content digest into the first of
+// them, which takes over their tags, digests and containers.  Children of the
+// folded images are moved onto the image they were folded into.
+func mergeByDigest(images []Image) []Image {
+	into := make(map[string]string)
+	byDigest := make(map[string]int)
+
+	var kept []Image
+	for _, image := range images {
+		index := -1
+		for _, digest := range image.RepoDigests {
+			if owner, exists := byDigest[contentDigest(digest)]; exists {
+				index = owner
+				break
+			}
+		}
+		if index < 0 {
+			kept = append(kept, image)
+			index = len(kept) - 1
+		} else {
+			target := &kept[index]
+			into[image.Id] = target.Id
+			target.RepoTags = mergeNames(target.RepoTags, image.RepoTags)
+			target.RepoDigests = mergeNames(target.RepoDigests, image.RepoDigests)
+			target.Containers += image.Containers
+		}
+		for _, digest := range image.RepoDigests {
+			if _, exists := byDigest[contentDigest(digest)]; !exists {
+				byDigest[contentDigest(digest)] = index
+			}
+		}
+	}
+
+	for index := range kept {
+		if target, exists := into[kept[index].ParentId]; exists && target != kept[index].Id {
+			kept[index].ParentId = target
+		}
+	}
+	return kept
+}
+
+// contentDigest drops the repository from a repo@sha256:... digest, as copies
+// of an image pushed to different repositories share only the hash.
+func contentDigest(repoDigest string) string {
+	if at := strings.LastIndex(repoDigest, "@"); at >= 0 {
+		return repoDigest[at+1:]
+	}
+	return repoDigest
+}
+
+// mergeNames returns a new list with the names of both lists, each once.  The
+// untagged placeholder is dropped when there are real names.
+func mergeNames(names, more []string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, name := range append(append([]string{}, names...), more...) {
+		if !seen[name] && name != "<none>:<none>" {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	if len(merged) == 0 && len(names)+len(more) > 0 {
+		merged = []string{"<none>:<none>"}
+	}
+	return merged
+}
+
+// promoteOrphans turns images whose parent is missing (e.g. removed, or left
+// out of partial input) into roots.  With hide set, orphans that are untagged
+// and have no children are dropped instead.
+func promoteOrphans(images []Image, hide bool) []Image {
+	present := make(map[string]bool)
+	for _, image := range images {
+		present[image.Id] = true
+	}
+	byParent := collectChildren(&images)
+
+	var kept []Image
+	for _, image := range images {
+		if len(image.ParentId) > 0 && !present[image.ParentId] {
+			if hide && !isTagged(image) && len(byParent[image.Id]) == 0 {
+				continue
+			}
+			image.ParentId = ""
+		}
+		kept = append(kept, image)
+	}
+
+	return kept
+}
+
+// collapseToTagged keeps only the tagged images, each linked to its nearest
+// tagged ancestor.  Where untagged layers were skipped in between, their
+// count and combined size are returned by the id of the image below them.
+func collapseToTagged(images []Image, sizeFormat string) ([]Image, map[string][]Image, map[string]string) {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+
+	var tagged []Image
+	skipped := make(map[string]string)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+
+		count, size := 0, int64(0)
+		parent, exists := byId[image.ParentId]
+		for exists && !isTagged(parent) {
+			count++
+			size += parent.Size
+			parent, exists = byId[parent.ParentId]
+		}
+
+		if !exists {
+			image.ParentId = ""
+		} else if count > 0 {
+			image.ParentId = parent.Id
+			layers := "layers"
+			if count == 1 {
+				layers = "layer"
+			}
+			skipped[image.Id] = fmt.Sprintf("%d %s, %s", count, layers, formatSize(size, sizeFormat))
+		}
+		tagged = append(tagged, image)
+	}
+
+	return collectRoots(&tagged), collectChildren(&tagged), skipped
+}
+
+// skipUntaggedRoots replaces each untagged root with its children, which
+// become roots in its place, until every root is tagged.
+func skipUntaggedRoots(roots []Image, byParent map[string][]Image) []Image {
+	var kept []Image
+	for _, root := range roots {
+		if isTagged(root) {
+			kept = append(kept, root)
+			continue
+		}
+		var children []Image
+		for _, child := range byParent[root.Id] {
+			child.ParentId = ""
+			child.InferredParent = false
+			children = append(children, child)
+		}
+		kept = append(kept, skipUntaggedRoots(children, byParent)...)
+	}
+	return kept
+}
+
+func filterImages(images *[]Image, byParent *map[string][]Image, keepUsed bool) (filteredImages []Image, filteredChildren map[string][]Image) {
 	for i := 0; i < len(*images); i++ {
 		// image is visible
 		//   1. it has a label
 		//   2. it is root
 		//   3. it is a node
-		var visible bool = (*images)[i].RepoTags[0] != "<none>:<none>" || (*images)[i].ParentId == "" || len((*byParent)[(*images)[i].Id]) > 1
+		//   4. a container uses it, when keepUsed is set
+		var visible bool = isTagged((*images)[i]) || (*images)[i].ParentId == "" || len((*byParent)[(*images)[i].Id]) > 1 || (keepUsed && (*images)[i].Containers > 0)
 		if visible {
 			filteredImages = append(filteredImages, (*images)[i])
 		} else {
@@ -231,71 +1743,501 @@ func filterImages(images *[]Image, byParent *map[string][]Image) (filteredImages
 		}
 	}
 
-	filteredChildren = collectChildren(&filteredImages)
+	filteredChildren = collectChildren(&filteredImages)
+
+	return filteredImages, filteredChildren
+}
+
+// selectImages keeps the images matching any of the include patterns along
+// with their ancestors (or every image, when there are no include patterns),
+// then drops the images matching an exclude pattern along with their
+// descendants.  Exclusion wins when an image matches both.
+func selectImages(images []Image, includes []string, excludes []string) []Image {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+
+	included := make(map[string]bool)
+	for _, image := range images {
+		if len(includes) > 0 && !matchesAny(image, includes) {
+			continue
+		}
+		for id := image.Id; id != "" && !included[id]; id = byId[id].ParentId {
+			included[id] = true
+		}
+	}
+
+	excluded := make(map[string]bool)
+	var isExcluded func(id string) bool
+	isExcluded = func(id string) bool {
+		image, exists := byId[id]
+		if !exists {
+			return false
+		}
+		if result, seen := excluded[id]; seen {
+			return result
+		}
+		excluded[id] = matchesAny(image, excludes) || isExcluded(image.ParentId)
+		return excluded[id]
+	}
+
+	var selected []Image
+	for _, image := range images {
+		if included[image.Id] && !isExcluded(image.Id) {
+			selected = append(selected, image)
+		}
+	}
+
+	return selected
+}
+
+// readFilterFile reads include and exclude patterns from a file, one per
+// line.  Exclude patterns start with "!"; blank lines and lines starting with
+// "#" are skipped.
+func readFilterFile(path string) ([]string, []string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to read filter file: %w", err)
+	}
+
+	var includes, excludes []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case len(line) == 0 || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "!"):
+			excludes = append(excludes, strings.TrimSpace(line[1:]))
+		default:
+			includes = append(includes, line)
+		}
+	}
+
+	return includes, excludes, nil
+}
+
+// withLabels keeps the images that have labels set, along with their
+// ancestors so the tree stays connected.
+func withLabels(images []Image) []Image {
+	var ids []string
+	for _, image := range images {
+		if len(image.Labels) > 0 {
+			ids = append(ids, image.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return selectImages(images, ids, nil)
+}
+
+// ancestorsOf keeps only the image and the chain of its parents up to its
+// root, in their original order.
+func ancestorsOf(images []Image, id string) []Image {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+	lineage := make(map[string]bool)
+	for ; id != "" && !lineage[id]; id = byId[id].ParentId {
+		lineage[id] = true
+	}
+
+	var kept []Image
+	for _, image := range images {
+		if lineage[image.Id] {
+			kept = append(kept, image)
+		}
+	}
+	return kept
+}
+
+// descendantsOf keeps only the image and its whole subtree, in their original
+// order, turning the image into a root.
+func descendantsOf(images []Image, id string) []Image {
+	var start []Image
+	for _, image := range images {
+		if image.Id == id {
+			start = append(start, image)
+		}
+	}
+	subtree := make(map[string]bool)
+	for _, image := range flattenTree(start, collectChildren(&images)) {
+		subtree[image.Id] = true
+	}
+
+	var kept []Image
+	for _, image := range images {
+		if subtree[image.Id] {
+			if image.Id == id {
+				image.ParentId = ""
+			}
+			kept = append(kept, image)
+		}
+	}
+	return kept
+}
+
+// dropEmpty removes the images without any size, virtual or their own, and
+// reparents their children onto the nearest ancestor that is kept.
+func dropEmpty(images []Image) []Image {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+	empty := func(image Image) bool {
+		return image.Size == 0 && image.VirtualSize == 0
+	}
+
+	var kept []Image
+	for _, image := range images {
+		if empty(image) {
+			continue
+		}
+		for parent, exists := byId[image.ParentId]; exists && empty(parent); parent, exists = byId[parent.ParentId] {
+			image.ParentId = parent.ParentId
+		}
+		kept = append(kept, image)
+	}
+
+	return kept
+}
+
+// matchesAny reports whether the image id starts with one of the patterns,
+// or one of its tags (or the repository of that tag) matches one as a glob.
+func matchesAny(image Image, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(image.Id, pattern) {
+			return true
+		}
+		for _, repotag := range image.RepoTags {
+			if repotag == "<none>:<none>" {
+				continue
+			}
+			if matched, _ := path.Match(pattern, repotag); matched {
+				return true
+			}
+			reponame, _ := splitRepoTag(repotag)
+			if matched, _ := path.Match(pattern, reponame); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// indentStyle holds the guides drawn in front of tree nodes: before a node
+// with siblings below it, before the last node, and below each of those.
+type indentStyle struct {
+	branch string
+	last   string
+	pipe   string
+	blank  string
+}
+
+var indentStyles = map[string]indentStyle{
+	"unicode": {"├─", "└─", "│ ", "  "},
+	"ascii":   {"|-", "`-", "| ", "  "},
+	"spaces":  {"  ", "  ", "  ", "  "},
+	"dots":    {"··", "··", "· ", "  "},
+}
+
+// id truncates an image id as selected, or leaves it whole with NoTruncate.
+func (opts TreeOptions) id(id string) string {
+	if opts.NoTruncate {
+		return id
+	}
+	return truncateTo(id, opts.IdLength)
+}
+
+// indent returns the selected indent style, unicode by default.
+func (opts TreeOptions) indent() indentStyle {
+	if style, exists := indentStyles[opts.IndentStyle]; exists {
+		return style
+	}
+	return indentStyles["unicode"]
+}
 
-	return filteredImages, filteredChildren
+// recentMarkup returns the terminal escapes that bold an image created since
+// RecentSince, or dim an older one.  Images without a creation time, and all
+// images when RecentSince isn't set or Plain is, are left plain.
+func (opts TreeOptions) recentMarkup(image Image) (string, string) {
+	if opts.RecentSince.IsZero() || opts.Plain || image.Created == 0 {
+		return "", ""
+	}
+	if image.Created >= opts.RecentSince.Unix() {
+		return "\x1b[1m", "\x1b[0m"
+	}
+	return "\x1b[2m", "\x1b[0m"
 }
 
-func jsonToText(buffer *bytes.Buffer, images []Image, byParent map[string][]Image, noTrunc bool, incremental bool, prefix string) {
+func jsonToText(buffer *bytes.Buffer, images []Image, byParent map[string][]Image, opts TreeOptions, prefix string) {
+	style := opts.indent()
 	var length = len(images)
 	if length > 1 {
 		for index, image := range images {
 			var nextPrefix string = ""
 			if index+1 == length {
-				PrintTreeNode(buffer, image, noTrunc, incremental, prefix+"└─")
-				nextPrefix = "  "
+				PrintTreeNode(buffer, image, opts, prefix+style.last)
+				nextPrefix = style.blank
 			} else {
-				PrintTreeNode(buffer, image, noTrunc, incremental, prefix+"├─")
-				nextPrefix = "│ "
+				PrintTreeNode(buffer, image, opts, prefix+style.branch)
+				nextPrefix = style.pipe
 			}
-			if subimages, exists := byParent[image.Id]; exists {
-				jsonToText(buffer, subimages, byParent, noTrunc, incremental, prefix+nextPrefix)
+			subimages, exists := byParent[image.Id]
+			childOpts, deeper := opts.descend(image)
+			exists = exists && deeper
+			printContainerLeaves(buffer, opts.Containers[image.Id], opts, prefix+nextPrefix, exists)
+			if exists {
+				jsonToText(buffer, subimages, byParent, childOpts, prefix+nextPrefix)
 			}
 		}
 	} else {
 		for _, image := range images {
-			PrintTreeNode(buffer, image, noTrunc, incremental, prefix+"└─")
-			if subimages, exists := byParent[image.Id]; exists {
-				jsonToText(buffer, subimages, byParent, noTrunc, incremental, prefix+"  ")
+			PrintTreeNode(buffer, image, opts, prefix+style.last)
+			subimages, exists := byParent[image.Id]
+			childOpts, deeper := opts.descend(image)
+			exists = exists && deeper
+			printContainerLeaves(buffer, opts.Containers[image.Id], opts, prefix+style.blank, exists)
+			if exists {
+				jsonToText(buffer, subimages, byParent, childOpts, prefix+style.blank)
 			}
 		}
 	}
 }
 
-func PrintTreeNode(buffer *bytes.Buffer, image Image, noTrunc bool, incremental bool, prefix string) {
-	var imageID string
-	if noTrunc {
-		imageID = image.Id
-	} else {
-		imageID = truncate(image.Id)
+// printContainerLeaves lists the containers of an image ahead of its child
+// images, which follow them when moreFollow is set.
+func printContainerLeaves(buffer *bytes.Buffer, containers []Container, opts TreeOptions, prefix string, moreFollow bool) {
+	style := opts.indent()
+	for index, container := range containers {
+		branch := style.branch
+		if index+1 == len(containers) && !moreFollow {
+			branch = style.last
+		}
+		containerID := container.Id
+		if !opts.NoTruncate {
+			containerID = truncate(containerID)
+		}
+		buffer.WriteString(fmt.Sprintf("%s%s%s Container: %s Status: %s\n", prefix, branch, containerID, containerName(container), container.Status))
 	}
+}
+
+func PrintTreeNode(buffer *bytes.Buffer, image Image, opts TreeOptions, prefix string) {
+	imageID := opts.id(image.Id)
 
 	var size int64
-	if incremental {
+	if opts.Incremental {
 		size = image.Size
 	} else {
 		size = image.VirtualSize
 	}
 
-	buffer.WriteString(fmt.Sprintf("%s%s Virtual Size: %s", prefix, imageID, humanSize(size)))
-	if image.RepoTags[0] != "<none>:<none>" {
-		buffer.WriteString(fmt.Sprintf(" Tags: %s\n", strings.Join(image.RepoTags, ", ")))
-	} else {
-		buffer.WriteString(fmt.Sprintf("\n"))
+	sizeLabel := "Virtual Size: "
+	if opts.NoSizeLabel {
+		sizeLabel = ""
+	}
+
+	line := fmt.Sprintf("%s%s %s%s", prefix, imageID, sizeLabel, formatSize(size, opts.SizeFormat))
+	if opts.PercentOf > 0 {
+		// the total is of own sizes, which virtual sizes would count repeatedly
+		line += fmt.Sprintf(" (%.1f%%)", float64(image.Size)*100/float64(opts.PercentOf))
+	}
+	markup, reset := opts.recentMarkup(image)
+	buffer.WriteString(prefix + markup + strings.TrimPrefix(line, prefix))
+	if isTagged(image) {
+		buffer.WriteString(" Tags: ")
+		wrapTags(buffer, image.RepoTags, prefix, opts.indent(), utf8.RuneCountInString(line+" Tags: "), opts.MaxWidth)
+		if count, exists := opts.LayerCounts[image.Id]; exists {
+			if count == 1 {
+				buffer.WriteString(" [1 layer]")
+			} else {
+				buffer.WriteString(fmt.Sprintf(" [%d layers]", count))
+			}
+		}
+	}
+	if image.InferredParent {
+		buffer.WriteString(" (inferred parent)")
+	}
+	if skipped, exists := opts.Skipped[image.Id]; exists {
+		buffer.WriteString(" (via " + skipped + ")")
+	}
+	if opts.Reclaimable[image.Id] {
+		buffer.WriteString(" [reclaimable]")
+	}
+	if opts.ShowUsage && image.Containers > 0 {
+		buffer.WriteString(fmt.Sprintf(" Containers: %d", image.Containers))
+	}
+	if opts.ShowDigests && len(image.RepoDigests) > 0 {
+		buffer.WriteString(fmt.Sprintf(" Digests: %s", strings.Join(image.RepoDigests, ", ")))
+	}
+	if opts.ShowHistory && len(image.History) > 0 {
+		buffer.WriteString(fmt.Sprintf(" Cmd: %s", createdBy(image.History[0], opts.NoTruncate)))
+	}
+	buffer.WriteString(reset + "\n")
+}
+
+// wrapTags writes a comma separated tag list starting at the given column,
+// moving tags that would run past maxWidth onto continuation lines aligned
+// under the first tag.  The tree guides of the node's prefix carry on down
+// the continuation lines.
+func wrapTags(buffer *bytes.Buffer, tags []string, prefix string, style indentStyle, column int, maxWidth int) {
+	guides := prefix
+	if strings.HasSuffix(prefix, style.branch) {
+		guides = strings.TrimSuffix(prefix, style.branch) + style.pipe
+	} else if strings.HasSuffix(prefix, style.last) {
+		guides = strings.TrimSuffix(prefix, style.last) + style.blank
+	}
+	indent := guides + strings.Repeat(" ", column-utf8.RuneCountInString(guides))
+
+	width := column
+	for index, tag := range tags {
+		if index > 0 {
+			if maxWidth > 0 && width+2+utf8.RuneCountInString(tag) > maxWidth {
+				buffer.WriteString(",\n" + indent)
+				width = column
+			} else {
+				buffer.WriteString(", ")
+				width += 2
+			}
+		}
+		buffer.WriteString(tag)
+		width += utf8.RuneCountInString(tag)
+	}
+}
+
+// createdBy shortens the command that created a layer for display.
+func createdBy(layer Layer, noTrunc bool) string {
+	command := strings.TrimPrefix(layer.CreatedBy, "/bin/sh -c ")
+	command = strings.TrimPrefix(command, "#(nop) ")
+	command = strings.Join(strings.Fields(command), " ")
+
+	if !noTrunc && len(command) > 60 {
+		command = command[0:57] + "..."
+	}
+
+	return command
+}
+
+// reclaimableImages lists the images that can be removed without affecting
+// anything else: untagged, without children and not used by any container.
+func reclaimableImages(images []Image) []Image {
+	byParent := collectChildren(&images)
+
+	var reclaimable []Image
+	for _, image := range images {
+		if !isTagged(image) && len(byParent[image.Id]) == 0 && image.Containers == 0 {
+			reclaimable = append(reclaimable, image)
+		}
+	}
+
+	return reclaimable
+}
+
+// reclaimableSummary is the footer totalling the space reclaimable images
+// take up.
+func reclaimableSummary(reclaimable []Image, sizeFormat string) string {
+	var total int64
+	for _, image := range reclaimable {
+		total += image.Size
+	}
+
+	return fmt.Sprintf("Reclaimable: %s in %d images\n", formatSize(total, sizeFormat), len(reclaimable))
+}
+
+// prunePlan writes a shell script removing the untagged images that nothing
+// depends on: no tags, no containers, and no children other than images
+// removed before them.  Children are always removed before their parents.
+func prunePlan(images []Image, sizeFormat string) string {
+	byParent := collectChildren(&images)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("#!/bin/sh\n")
+	var total int64
+	var plan func(image Image) bool
+	plan = func(image Image) bool {
+		removable := !isTagged(image) && image.Containers == 0
+		for _, child := range byParent[image.Id] {
+			if !plan(child) {
+				removable = false
+			}
+		}
+		if removable {
+			buffer.WriteString(fmt.Sprintf("docker rmi %s\n", image.Id))
+			total += image.Size
+		}
+		return removable
+	}
+	for _, root := range collectRoots(&images) {
+		plan(root)
+	}
+	buffer.WriteString(fmt.Sprintf("# total reclaimable: %s\n", formatSize(total, sizeFormat)))
+
+	return buffer.String()
+}
+
+// layerCounts maps each image id to the number of layers it is built from:
+// the image itself plus all of its ancestors.
+func layerCounts(images []Image) map[string]int {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+
+	counts := make(map[string]int)
+	var count func(id string) int
+	count = func(id string) int {
+		image, exists := byId[id]
+		if !exists {
+			return 0
+		}
+		if layers, seen := counts[id]; seen {
+			return layers
+		}
+		counts[id] = count(image.ParentId) + 1
+		return counts[id]
+	}
+	for _, image := range images {
+		count(image.Id)
+	}
+
+	return counts
+}
+
+// isTagged reports whether the image carries a real tag, rather than none
+// at all or the "<none>:<none>" placeholder.
+func isTagged(image Image) bool {
+	return len(image.RepoTags) > 0 && image.RepoTags[0] != "<none>:<none>"
+}
+
+// formatSize renders a byte count in the requested format: "si" (the
+// default, 1000-based units), "iec" (1024-based units) or "raw" bytes.
+func formatSize(raw int64, format string) string {
+	switch format {
+	case "iec":
+		return scaleSize(raw, 1024, []string{"B", "KiB", "MiB", "GiB", "TiB"})
+	case "raw":
+		return strconv.FormatInt(raw, 10)
+	default:
+		return humanSize(raw)
 	}
 }
 
 func humanSize(raw int64) string {
-	sizes := []string{"B", "KB", "MB", "GB", "TB"}
+	return scaleSize(raw, 1000, []string{"B", "KB", "MB", "GB", "TB"})
+}
 
+func scaleSize(raw int64, base float64, sizes []string) string {
 	rawFloat := float64(raw)
 	ind := 0
 
 	for {
-		if rawFloat < 1000 {
+		if rawFloat < base || ind == len(sizes)-1 {
 			break
 		} else {
-			rawFloat = rawFloat / 1000
+			rawFloat = rawFloat / base
 			ind = ind + 1
 		}
 	}
@@ -303,8 +2245,112 @@ func humanSize(raw int64) string {
 	return fmt.Sprintf("%.01f %s", rawFloat, sizes[ind])
 }
 
+// defaultTruncLength is how many characters of an id are shown, unless
+// --trunc-length picks another length for image ids.
+const defaultTruncLength = 12
+
+// sizePattern matches sizes such as 512, 1.5GB or 200MiB.
+var sizePattern = regexp.MustCompile(`^(?i)(\d+(?:\.\d+)?)\s*(?:([kmgt])(i)?b?|b)?$`)
+
+// parseSize parses a size with an optional SI (KB, MB, ...) or IEC (KiB,
+// MiB, ...) unit into bytes.
+func parseSize(size string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0, fmt.Errorf("Invalid size '%s', expected e.g. 500MB or 2GiB.", size)
+	}
+
+	value, _ := strconv.ParseFloat(match[1], 64)
+	base := 1000.0
+	if len(match[3]) > 0 {
+		base = 1024
+	}
+	if len(match[2]) > 0 {
+		value *= math.Pow(base, float64(strings.Index("kmgt", strings.ToLower(match[2]))+1))
+	}
+
+	return int64(value), nil
+}
+
+// checkSizeBudget returns an error listing every image whose virtual size is
+// over the budget.
+func checkSizeBudget(images []Image, budget int64, sizeFormat string, idLength int) error {
+	var over []string
+	for _, image := range images {
+		if image.VirtualSize > budget {
+			name := truncateTo(image.Id, idLength)
+			if isTagged(image) {
+				name = strings.Join(image.RepoTags, ", ")
+			}
+			over = append(over, fmt.Sprintf("  %s (%s)", name, formatSize(image.VirtualSize, sizeFormat)))
+		}
+	}
+	if len(over) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d images are over the size budget of %s:\n%s", len(over), formatSize(budget, sizeFormat), strings.Join(over, "\n"))
+}
+
 func truncate(id string) string {
-	return id[0:12]
+	return truncateTo(id, defaultTruncLength)
+}
+
+// truncateTo shortens an id to length characters, defaultTruncLength when
+// length isn't set.
+func truncateTo(id string, length int) string {
+	if length < 1 {
+		length = defaultTruncLength
+	}
+	if len(id) <= length {
+		return id
+	}
+	return id[0:length]
+}
+
+// distinctLength returns the shortest truncation length, starting at length,
+// at which no two of the image ids share a prefix.
+func distinctLength(images []Image, length int) int {
+	for {
+		seen := make(map[string]bool)
+		collision, longer := false, false
+		for _, image := range images {
+			if len(image.Id) > length {
+				longer = true
+			}
+			prefix := image.Id
+			if len(prefix) > length {
+				prefix = prefix[0:length]
+			}
+			if seen[prefix] {
+				collision = true
+			}
+			seen[prefix] = true
+		}
+		if !collision || !longer {
+			return length
+		}
+		length++
+	}
+}
+
+// checkTruncation returns the truncation length to use, lengthening it with
+// a warning when distinct ids would otherwise be shown the same.  In strict
+// mode the collision is an error instead.
+func checkTruncation(images []Image, length int, warnings io.Writer, strict bool) (int, error) {
+	if length < 1 {
+		return 0, fmt.Errorf("--trunc-length must be at least 1")
+	}
+
+	safe := distinctLength(images, length)
+	if safe > length {
+		if strict {
+			return 0, fmt.Errorf("Image ids are not distinct when truncated to %d characters, %d are needed.", length, safe)
+		}
+		fmt.Fprintf(warnings, "Warning: showing %d characters of image ids, as %d would not keep them distinct\n", safe, length)
+	}
+
+	return safe, nil
 }
 
 func parseImagesJSON(rawJSON []byte) (*[]Image, error) {
@@ -313,59 +2359,459 @@ func parseImagesJSON(rawJSON []byte) (*[]Image, error) {
 	err := json.Unmarshal(rawJSON, &images)
 
 	if err != nil {
-		return nil, fmt.Errorf("Error reading JSON: ", err)
+		return nil, newKindError(ErrParse, "Error reading JSON: %w", err)
 	}
 
 	return &images, nil
 }
 
-func imagesToDot(buffer *bytes.Buffer, images []Image, byParent map[string][]Image) {
+func imagesToDot(buffer *bytes.Buffer, images []Image, byParent map[string][]Image, colors map[string]string, opts DotOptions) {
 	for _, image := range images {
 		if image.ParentId == "" {
-			buffer.WriteString(fmt.Sprintf(" base -> \"%s\" [style=invis]\n", truncate(image.Id)))
+			buffer.WriteString(fmt.Sprintf(" %s -> \"%s\" [style=invis]\n", opts.base(), opts.id(image.Id)))
 		} else {
-			buffer.WriteString(fmt.Sprintf(" \"%s\" -> \"%s\"\n", truncate(image.ParentId), truncate(image.Id)))
+			var edgeAttrs []string
+			if image.InferredParent {
+				edgeAttrs = append(edgeAttrs, "style=dashed")
+			}
+			if skipped, exists := opts.Skipped[image.Id]; exists {
+				edgeAttrs = append(edgeAttrs, fmt.Sprintf("label=\"%s\"", dotEscape(skipped)))
+			}
+			edgeStyle := ""
+			if len(edgeAttrs) > 0 {
+				edgeStyle = " [" + strings.Join(edgeAttrs, ",") + "]"
+			}
+			buffer.WriteString(fmt.Sprintf(" \"%s\" -> \"%s\"%s\n", opts.id(image.ParentId), opts.id(image.Id), edgeStyle))
+		}
+		fillcolor, colored := colors[image.Id]
+		if isTagged(image) {
+			if !colored {
+				fillcolor = "paleturquoise"
+			}
+			if opts.TagNodes {
+				buffer.WriteString(fmt.Sprintf(" \"%s\" [label=\"%s\",shape=%s,fillcolor=\"%s\",style=\"filled,rounded\"];\n", opts.id(image.Id), opts.nodeLabel(opts.id(image.Id)), opts.shape(), fillcolor))
+				for _, repotag := range image.RepoTags {
+					buffer.WriteString(fmt.Sprintf(" \"%s\" [shape=ellipse];\n \"%s\" -> \"%s\"\n", dotEscape(repotag), dotEscape(repotag), opts.id(image.Id)))
+				}
+			} else {
+				label := opts.nodeLabel(append([]string{opts.id(image.Id)}, image.RepoTags...)...)
+				if opts.LabelsOnly {
+					label = opts.nodeLabel(image.RepoTags...)
+				}
+				buffer.WriteString(fmt.Sprintf(" \"%s\" [label=\"%s\",shape=%s,fillcolor=\"%s\",style=\"filled,rounded\"];\n", opts.id(image.Id), label, opts.shape(), fillcolor))
+			}
+		} else if _, hasChildren := byParent[image.Id]; opts.MarkDangling && !hasChildren {
+			if !colored {
+				fillcolor = "lightgray"
+			}
+			buffer.WriteString(fmt.Sprintf(" \"%s\" [label=\"%s\",shape=%s,fillcolor=\"%s\",style=\"filled,dashed\"];\n", opts.id(image.Id), opts.nodeLabel(opts.id(image.Id)), opts.shape(), fillcolor))
+		} else if opts.AllNodes {
+			if !colored {
+				fillcolor = "lightgray"
+			}
+			buffer.WriteString(fmt.Sprintf(" \"%s\" [label=\"%s\",shape=%s,fillcolor=\"%s\",style=filled];\n", opts.id(image.Id), opts.nodeLabel(opts.id(image.Id)), opts.shape(), fillcolor))
+		} else if colored {
+			buffer.WriteString(fmt.Sprintf(" \"%s\" [fillcolor=\"%s\",style=filled];\n", opts.id(image.Id), fillcolor))
 		}
-		if image.RepoTags[0] != "<none>:<none>" {
-			buffer.WriteString(fmt.Sprintf(" \"%s\" [label=\"%s\\n%s\",shape=box,fillcolor=\"paleturquoise\",style=\"filled,rounded\"];\n", truncate(image.Id), truncate(image.Id), strings.Join(image.RepoTags, "\\n")))
+		if opts.FullIdTitle {
+			buffer.WriteString(fmt.Sprintf(" \"%s\" [id=\"%s\",tooltip=\"%s\"];\n", opts.id(image.Id), image.Id, image.Id))
 		}
 		if subimages, exists := byParent[image.Id]; exists {
-			imagesToDot(buffer, subimages, byParent)
+			imagesToDot(buffer, subimages, byParent, colors, opts)
+		}
+	}
+}
+
+// ageColors maps each image to a fill color on the palette's gradient from
+// the newest (green by default) to the oldest (gray) image.  Images without a
+// creation time get a neutral white.
+func ageColors(images []Image, colorScheme palette) map[string]string {
+	oldest, newest := createdRange(images)
+
+	colors := make(map[string]string)
+	for _, image := range images {
+		if image.Created == 0 {
+			colors[image.Id] = "white"
+			continue
+		}
+		var age float64
+		if newest > oldest {
+			age = float64(newest-image.Created) / float64(newest-oldest)
+		}
+		colors[image.Id] = colorScheme.gradient(age)
+	}
+
+	return colors
+}
+
+// recentColors fills images created since the cutoff gold and older ones a
+// faint gray.  Images without a creation time are left uncolored.
+func recentColors(images []Image, since time.Time) map[string]string {
+	colors := make(map[string]string)
+	for _, image := range images {
+		switch {
+		case image.Created == 0:
+		case image.Created >= since.Unix():
+			colors[image.Id] = "gold"
+		default:
+			colors[image.Id] = "gray90"
+		}
+	}
+	return colors
+}
+
+// createdRange returns the oldest and newest creation times, ignoring images
+// without one.
+func createdRange(images []Image) (oldest, newest int64) {
+	for _, image := range images {
+		if image.Created == 0 {
+			continue
+		}
+		if oldest == 0 || image.Created < oldest {
+			oldest = image.Created
+		}
+		if image.Created > newest {
+			newest = image.Created
+		}
+	}
+	return oldest, newest
+}
+
+// ageLegend writes a cluster of swatches for the ends and middle of the age
+// gradient, labeled with the creation dates they stand for.
+func ageLegend(buffer *bytes.Buffer, images []Image, colorScheme palette) {
+	oldest, newest := createdRange(images)
+	if newest == 0 {
+		return
+	}
+
+	buffer.WriteString(" subgraph cluster_legend {\n  label=\"Created\"\n")
+	var previous string
+	for index, fraction := range []float64{0, 0.5, 1} {
+		created := newest - int64(float64(newest-oldest)*fraction)
+		swatch := fmt.Sprintf("legend%d", index)
+		buffer.WriteString(fmt.Sprintf("  %s [label=\"%s\",shape=box,fillcolor=\"%s\",style=\"filled\"];\n", swatch, time.Unix(created, 0).UTC().Format("2006-01-02"), colorScheme.gradient(fraction)))
+		if len(previous) > 0 {
+			buffer.WriteString(fmt.Sprintf("  %s -> %s [style=invis]\n", previous, swatch))
+		}
+		previous = swatch
+	}
+	buffer.WriteString(" }\n")
+}
+
+// splitRepoTag parses the repo name and tag name out of a repo tag.  The
+// tag is after the last colon, unless that colon comes before the last slash
+// and so separates a registry host from its port.  The tag is empty when
+// there is none.
+func splitRepoTag(repotag string) (string, string) {
+	lastColonIndex := strings.LastIndex(repotag, ":")
+	if lastColonIndex < 0 || lastColonIndex < strings.LastIndex(repotag, "/") {
+		return repotag, ""
+	}
+
+	return repotag[0:lastColonIndex], repotag[lastColonIndex+1:]
+}
+
+// repoTotals summarizes each repository's image count and size, largest
+// first.  Images tagged more than once in a repository are counted once.
+func repoTotals(images []Image, sizeFormat string) string {
+	type repoTotal struct {
+		name   string
+		images int
+		size   int64
+	}
+
+	byRepo := make(map[string]*repoTotal)
+	counted := make(map[string]bool)
+	for _, image := range images {
+		for _, repotag := range image.RepoTags {
+			if repotag == "<none>:<none>" {
+				continue
+			}
+			reponame, _ := splitRepoTag(repotag)
+			if counted[reponame+" "+image.Id] {
+				continue
+			}
+			counted[reponame+" "+image.Id] = true
+
+			total, exists := byRepo[reponame]
+			if !exists {
+				total = &repoTotal{name: reponame}
+				byRepo[reponame] = total
+			}
+			total.images++
+			total.size += image.VirtualSize
+		}
+	}
+
+	var totals []*repoTotal
+	for _, total := range byRepo {
+		totals = append(totals, total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].size != totals[j].size {
+			return totals[i].size > totals[j].size
+		}
+		return totals[i].name < totals[j].name
+	})
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "REPOSITORY\tIMAGES\tSIZE")
+	for _, total := range totals {
+		fmt.Fprintf(writer, "%s\t%d\t%s\n", total.name, total.images, formatSize(total.size, sizeFormat))
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
+
+type ShortOptions struct {
+	WithDepth bool
+	Expand    bool
+
+	// follow each tag with its image's age, as of Now (or the current time)
+	WithAge bool
+	Now     time.Time
+
+	// one line per image with all of its tags, instead of per repository
+	Flatten bool
+
+	// one line per repo:tag, sorted
+	Oneline bool
+
+	// sort a repository's latest tag after its versions instead of first
+	LatestLast bool
+
+	// pad repository names so the tags line up, to at most MaxAlign
+	Align    bool
+	MaxAlign int
+
+	// repository order: "name" (the default), "tags", "size" or "lineage"
+	SortBy  string
+	Reverse bool
+
+	// how each repository's lineage is printed with Expand
+	Tree TreeOptions
+}
+
+// flattenShort lists each tagged image once, by id, with all of its tags
+// whatever their repository.
+func flattenShort(images []Image, opts TreeOptions) string {
+	var tagged []Image
+	for _, image := range images {
+		if isTagged(image) {
+			tagged = append(tagged, image)
+		}
+	}
+	sort.Slice(tagged, func(i, j int) bool {
+		return tagged[i].Id < tagged[j].Id
+	})
+
+	var buffer bytes.Buffer
+	for _, image := range tagged {
+		imageID := opts.id(image.Id)
+		buffer.WriteString(fmt.Sprintf("%s: %s\n", imageID, strings.Join(image.RepoTags, ", ")))
+	}
+
+	return buffer.String()
+}
+
+// onelineShort lists every repo:tag on its own line, sorted.
+func onelineShort(images []Image) string {
+	seen := make(map[string]bool)
+	var repotags []string
+	for _, image := range images {
+		for _, repotag := range image.RepoTags {
+			if repotag != "<none>:<none>" && !seen[repotag] {
+				seen[repotag] = true
+				repotags = append(repotags, repotag)
+			}
 		}
 	}
+	sort.Strings(repotags)
+
+	var buffer bytes.Buffer
+	for _, repotag := range repotags {
+		buffer.WriteString(repotag + "\n")
+	}
+
+	return buffer.String()
 }
 
-func jsonToShort(images *[]Image) string {
+func jsonToShort(images *[]Image, opts ShortOptions) string {
+	if opts.Oneline {
+		return onelineShort(*images)
+	}
+	if opts.Flatten {
+		return flattenShort(*images, opts.Tree)
+	}
+
 	var buffer bytes.Buffer
 
 	var byRepo = make(map[string][]string)
+	var depthByRepo = make(map[string]int)
+	var sizeByRepo = make(map[string]int64)
+	var counted = make(map[string]bool)
+	var imageByTag = make(map[string]Image)
+
+	var counts map[string]int
+	if opts.WithDepth {
+		counts = layerCounts(*images)
+	}
+
+	// a repository weighs as much as is built on its images
+	var lineage map[string]int64
+	var lineageByRepo = make(map[string]int64)
+	if opts.SortBy == "lineage" {
+		lineage = descendantSizes(*images)
+	}
 
 	for _, image := range *images {
 		for _, repotag := range image.RepoTags {
 			if repotag != "<none>:<none>" {
 
-				// parse the repo name and tag name out
-				// tag is after the last colon
-				lastColonIndex := strings.LastIndex(repotag, ":")
-				tagname := repotag[lastColonIndex+1:]
-				reponame := repotag[0:lastColonIndex]
+				reponame, tagname := splitRepoTag(repotag)
+				imageByTag[reponame+":"+tagname] = image
 
 				if tags, exists := byRepo[reponame]; exists {
 					byRepo[reponame] = append(tags, tagname)
 				} else {
 					byRepo[reponame] = []string{tagname}
 				}
+
+				// depth is the number of ancestors below the image
+				if depth := counts[image.Id] - 1; depth > depthByRepo[reponame] {
+					depthByRepo[reponame] = depth
+				}
+
+				if lineage[image.Id] > lineageByRepo[reponame] {
+					lineageByRepo[reponame] = lineage[image.Id]
+				}
+
+				// images tagged twice in a repository count once
+				if !counted[reponame+" "+image.Id] {
+					counted[reponame+" "+image.Id] = true
+					sizeByRepo[reponame] += image.VirtualSize
+				}
+			}
+		}
+	}
+
+	var repos []string
+	for repo := range byRepo {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		a, b := repos[i], repos[j]
+		if opts.Reverse {
+			a, b = b, a
+		}
+		switch opts.SortBy {
+		case "tags":
+			if len(byRepo[a]) != len(byRepo[b]) {
+				return len(byRepo[a]) < len(byRepo[b])
+			}
+		case "size":
+			if sizeByRepo[a] != sizeByRepo[b] {
+				return sizeByRepo[a] < sizeByRepo[b]
+			}
+		case "lineage":
+			if lineageByRepo[a] != lineageByRepo[b] {
+				return lineageByRepo[a] > lineageByRepo[b]
+			}
+		}
+		return a < b
+	})
+
+	var width int
+	if opts.Align {
+		for _, repo := range repos {
+			if len(repo) > width {
+				width = len(repo)
 			}
 		}
+		if opts.MaxAlign > 0 && width > opts.MaxAlign {
+			width = opts.MaxAlign
+		}
 	}
 
-	for repo, tags := range byRepo {
-		buffer.WriteString(fmt.Sprintf("%s: %s\n", repo, strings.Join(tags, ", ")))
+	for _, repo := range repos {
+		tags := byRepo[repo]
+		sortTags(tags, opts.LatestLast)
+		if opts.WithAge {
+			now := opts.Now
+			if now.IsZero() {
+				now = time.Now()
+			}
+			for index, tag := range tags {
+				if created := imageByTag[repo+":"+tag].Created; created != 0 {
+					tags[index] = fmt.Sprintf("%s (%s)", tag, shortAge(now.Sub(time.Unix(created, 0))))
+				}
+			}
+		}
+		buffer.WriteString(fmt.Sprintf("%-*s %s", width+1, repo+":", strings.Join(tags, ", ")))
+		if opts.WithDepth {
+			buffer.WriteString(fmt.Sprintf(" (depth %d)", depthByRepo[repo]))
+		}
+		buffer.WriteString("\n")
+		if opts.Expand {
+			lineage := repoLineage(*images, repo)
+			roots, byParent := prepareTree(&lineage, nil, false)
+			jsonToText(&buffer, roots, byParent, opts.Tree, "  ")
+		}
 	}
 
 	return buffer.String()
 }
 
+// shortAge rounds an age down to whole minutes, hours, days or years.
+func shortAge(age time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age/time.Minute))
+	case age < day:
+		return fmt.Sprintf("%dh", int(age/time.Hour))
+	case age < 365*day:
+		return fmt.Sprintf("%dd", int(age/day))
+	}
+	return fmt.Sprintf("%dy", int(age/(365*day)))
+}
+
+// repoLineage selects the images tagged in a repository along with all of
+// their ancestors.
+func repoLineage(images []Image, repo string) []Image {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+
+	keep := make(map[string]bool)
+	for _, image := range images {
+		inRepo := false
+		for _, repotag := range image.RepoTags {
+			if repotag != "<none>:<none>" {
+				if reponame, _ := splitRepoTag(repotag); reponame == repo {
+					inRepo = true
+				}
+			}
+		}
+		for current, exists := image, inRepo; exists && !keep[current.Id]; current, exists = byId[current.ParentId] {
+			keep[current.Id] = true
+		}
+	}
+
+	var lineage []Image
+	for _, image := range images {
+		if keep[image.Id] {
+			lineage = append(lineage, image)
+		}
+	}
+
+	return lineage
+}
+
 func init() {
 	parser.AddCommand("images",
 		"Visualize docker images.",
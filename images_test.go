@@ -1,8 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
 )
 
 type DotTest struct {
@@ -23,6 +35,8 @@ type TreeTest struct {
 	regexps    []string
 }
 
+const treeJSON = `[{"VirtualSize":674553464,"Size":2000000,"RepoTags":["foo:latest"],"ParentId":"735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Id":"c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470","Created":1386142123},{"VirtualSize":682553464,"Size":20000000,"RepoTags":["<none>:<none>"],"ParentId":"4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358","Id":"626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Created":1386142123},{"VirtualSize":712553464,"Size":30000000,"RepoTags":["base:latest"],"ParentId":"626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Id":"574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870","Created":1386142123},{"VirtualSize":752553464,"Size":40000000,"RepoTags":["<none>:<none>"],"ParentId":"574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870","Id":"aaf8d4d1bccab994574c5f626147582d2ae3735f5db5f2c87be8e5e697c08870","Created":1386142123},{"VirtualSize":672553464,"Size":10000000,"RepoTags":["<none>:<none>"],"ParentId":"4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358","Id":"735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Created":1386142123},{"VirtualSize":662553464,"Size":662553464,"RepoTags":["<none>:<none>"],"ParentId":"","Id":"4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358","Created":1386114144}]`
+
 func Test_BadJSON(t *testing.T) {
 	_, err := parseImagesJSON([]byte(` "VirtualSize": 662553464, "Size": 662553464, "RepoTags": [ "<none>:<none>" ], "ParentId": "", "Id": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Created": 1386114144 }]`))
 
@@ -31,6 +45,260 @@ func Test_BadJSON(t *testing.T) {
 	}
 }
 
+// executeImages runs the images command on input given as stdin, returning
+// what it printed to stdout.
+func executeImages(t *testing.T, command ImagesCommand, input string, args ...string) (string, error) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "images.json"), []byte(input), 0644)
+	stdin, _ := os.Open(filepath.Join(dir, "images.json"))
+	defer stdin.Close()
+
+	return runImages(t, command, stdin, args)
+}
+
+// stubDaemon lists the images, and has nothing else to tell.
+type stubDaemon struct {
+	stubImageLister
+}
+
+func (stubDaemon) ImageHistory(name string) ([]docker.ImageHistory, error) {
+	return nil, fmt.Errorf("no history for %s", name)
+}
+
+func (stubDaemon) ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	return nil, nil
+}
+
+func (stubDaemon) Version() (*docker.Env, error) {
+	return &docker.Env{}, nil
+}
+
+// executeDaemonImages runs the images command against a daemon listing the
+// given images.
+func executeDaemonImages(t *testing.T, command ImagesCommand, listed stubImageLister, args ...string) (string, error) {
+	original := connectDaemon
+	defer func() { connectDaemon = original }()
+	connectDaemon = func() (daemonClient, error) {
+		return stubDaemon{listed}, nil
+	}
+
+	// nothing piped in, so the images come from the daemon
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	return runImages(t, command, stdin, args)
+}
+
+func runImages(t *testing.T, command ImagesCommand, stdin *os.File, args []string) (string, error) {
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalIn, originalOut, originalCommand := os.Stdin, os.Stdout, imagesCommand
+	defer func() { os.Stdin, os.Stdout, imagesCommand = originalIn, originalOut, originalCommand }()
+	os.Stdin, os.Stdout = stdin, stdout
+
+	imagesCommand = command
+	err = imagesCommand.Execute(args)
+	written, _ := ioutil.ReadFile(stdout.Name())
+	return string(written), err
+}
+
+func Test_Count(t *testing.T) {
+	written, err := executeImages(t, ImagesCommand{Count: true, Include: []string{"base"}, TruncLength: 12}, treeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// base:latest and its two ancestors
+	if written != "3\n" {
+		t.Fatalf("expected a count of 3, got '%s'", written)
+	}
+
+	written, _ = executeImages(t, ImagesCommand{Count: true, Exclude: []string{"626147582d2a"}, TruncLength: 12}, treeJSON)
+	if written != "3\n" {
+		t.Fatalf("expected a count of 3 after excluding a subtree, got '%s'", written)
+	}
+
+	written, _ = executeImages(t, ImagesCommand{Count: true, Include: []string{"missing"}, TruncLength: 12}, treeJSON)
+	if written != "0\n" {
+		t.Fatalf("expected a count of 0 when nothing matches, got '%s'", written)
+	}
+}
+
+func Test_AncestorOf(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	var ids []string
+	for _, image := range ancestorsOf(*im, "aaf8d4d1bccab994574c5f626147582d2ae3735f5db5f2c87be8e5e697c08870") {
+		ids = append(ids, truncate(image.Id))
+	}
+	if strings.Join(ids, " ") != "626147582d2a 574c5faaf8d4 aaf8d4d1bcca 4c1208b690c6" {
+		t.Fatalf("unexpected lineage %v", ids)
+	}
+
+	written, err := executeImages(t, ImagesCommand{AncestorOf: "base", Tree: true, AllLayers: true, TruncLength: 12}, treeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `└─4c1208b690c6 Virtual Size: 662.6 MB
+  └─626147582d2a Virtual Size: 682.6 MB
+    └─574c5faaf8d4 Virtual Size: 712.6 MB Tags: base:latest
+`
+	if written != expected {
+		t.Fatalf("expected only the lineage of base, got '%s'", written)
+	}
+
+	if _, err := executeImages(t, ImagesCommand{AncestorOf: "missing", Tree: true, TruncLength: 12}, treeJSON); err == nil {
+		t.Fatal("expected an error for an unknown image")
+	}
+}
+
+func Test_DescendantOf(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	var ids []string
+	for _, image := range descendantsOf(*im, "626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470") {
+		ids = append(ids, truncate(image.Id))
+	}
+	if strings.Join(ids, " ") != "626147582d2a 574c5faaf8d4 aaf8d4d1bcca" {
+		t.Fatalf("unexpected subtree %v", ids)
+	}
+
+	written, err := executeImages(t, ImagesCommand{DescendantOf: "base", Tree: true, AllLayers: true, TruncLength: 12}, treeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `└─574c5faaf8d4 Virtual Size: 712.6 MB Tags: base:latest
+  └─aaf8d4d1bcca Virtual Size: 752.6 MB
+`
+	if written != expected {
+		t.Fatalf("expected only the subtree of base, got '%s'", written)
+	}
+}
+
+const migratedJSON = `[{"Id":"1111111111111111","ParentId":"","RepoTags":["debian:12"],"Size":100},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["app:1.0"],"RepoDigests":["app@sha256:abc"],"Size":10},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["mirror/app:1.0","app:1.0"],"RepoDigests":["mirror/app@sha256:abc"],"Size":10},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["worker:latest"],"Size":5}]`
+
+func Test_MergeByDigest(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(migratedJSON))
+	merged := mergeByDigest(*im)
+
+	if len(merged) != 3 {
+		t.Fatalf("expected the two copies to merge into one image, got %v", merged)
+	}
+	app := merged[1]
+	if app.Id != "2222222222222222" || strings.Join(app.RepoTags, ",") != "app:1.0,mirror/app:1.0" {
+		t.Fatalf("unexpected merged image %v", app)
+	}
+	if strings.Join(app.RepoDigests, ",") != "app@sha256:abc,mirror/app@sha256:abc" {
+		t.Fatalf("unexpected merged digests %v", app.RepoDigests)
+	}
+	if merged[2].ParentId != app.Id {
+		t.Fatalf("child of the folded copy was not moved, parent is %s", merged[2].ParentId)
+	}
+	if (*im)[1].RepoTags[0] != "app:1.0" || len((*im)[1].RepoTags) != 1 {
+		t.Fatalf("input image was modified: %v", (*im)[1].RepoTags)
+	}
+
+	roots, byParent := prepareTree(&merged, nil, false)
+	result := jsonToDot(roots, byParent, DotOptions{})
+	if strings.Contains(result, "333333333333") || !strings.Contains(result, ` "222222222222" [label="222222222222\napp:1.0\nmirror/app:1.0",`) {
+		t.Fatalf("expected a single merged node in '%s'", result)
+	}
+
+	written, err := executeImages(t, ImagesCommand{MergeDigests: true, Tree: true, TruncLength: 12}, migratedJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `└─111111111111 Virtual Size: 0.0 B Tags: debian:12
+  └─222222222222 Virtual Size: 0.0 B Tags: app:1.0, mirror/app:1.0
+    └─444444444444 Virtual Size: 0.0 B Tags: worker:latest
+`
+	if written != expected {
+		t.Fatalf("unexpected tree '%s'", written)
+	}
+}
+
+func Test_DuplicateTags(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["app:latest","app:1.0"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["app:latest"]},{"Id":"3333333333333333","ParentId":"","RepoTags":["<none>:<none>"]},{"Id":"4444444444444444","ParentId":"","RepoTags":["<none>:<none>"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	var warnings bytes.Buffer
+	if err := checkDuplicateTags(*im, 12, &warnings, false); err != nil {
+		t.Fatalf("unexpected error without strict: %s", err)
+	}
+	expected := "Warning: tag app:latest points at multiple images: 111111111111, 222222222222\n"
+	if warnings.String() != expected {
+		t.Fatalf("duplicate tag warning was '%s', expected '%s'", warnings.String(), expected)
+	}
+
+	warnings.Reset()
+	if err := checkDuplicateTags(*im, 12, &warnings, true); err == nil {
+		t.Fatal("duplicate tags did not cause an error in strict mode")
+	}
+
+	warnings.Reset()
+	im, _ = parseImagesJSON([]byte(treeJSON))
+	if err := checkDuplicateTags(*im, 12, &warnings, true); err != nil || warnings.Len() > 0 {
+		t.Fatalf("unexpected duplicate tag warning '%s' (%v)", warnings.String(), err)
+	}
+}
+
+func Test_TagCounts(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["app:1.0","app:1.1","app:1.2"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["app:latest","base:latest"]},{"Id":"3333333333333333","ParentId":"","RepoTags":["<none>:<none>"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	// four tags sit right at the threshold
+	var warnings bytes.Buffer
+	if err := checkTagCounts(*im, 4, &warnings, true); err != nil || warnings.Len() > 0 {
+		t.Fatalf("unexpected tag count warning '%s' (%v)", warnings.String(), err)
+	}
+
+	if err := checkTagCounts(*im, 3, &warnings, false); err != nil {
+		t.Fatalf("unexpected error without strict: %s", err)
+	}
+	expected := "Warning: repository app has 4 tags, more than 3\n"
+	if warnings.String() != expected {
+		t.Fatalf("tag count warning was '%s', expected '%s'", warnings.String(), expected)
+	}
+
+	warnings.Reset()
+	if err := checkTagCounts(*im, 3, &warnings, true); err == nil || err.Error() != "Found 1 repositories with more than 3 tags." {
+		t.Fatalf("expected an error in strict mode, got %v", err)
+	}
+}
+
+func Test_NoImages(t *testing.T) {
+	// a fresh daemon has no images at all
+	empty, _ := parseImagesJSON([]byte(`[]`))
+
+	// or the filters leave nothing behind
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	filtered := selectImages(*im, []string{"no-such-repo"}, nil)
+
+	for _, images := range [][]Image{*empty, filtered} {
+		var warnings bytes.Buffer
+		isEmpty, err := checkNoImages(images, &warnings, false)
+		if !isEmpty || err != nil {
+			t.Fatalf("expected an empty set without an error, got %v, %v", isEmpty, err)
+		}
+		if warnings.String() != "no images to display\n" {
+			t.Fatalf("unexpected warning '%s'", warnings.String())
+		}
+
+		warnings.Reset()
+		isEmpty, err = checkNoImages(images, &warnings, true)
+		if !isEmpty || err != errNoImages {
+			t.Fatalf("expected errNoImages in strict mode, got %v, %v", isEmpty, err)
+		}
+	}
+
+	if isEmpty, err := checkNoImages(*im, &bytes.Buffer{}, true); isEmpty || err != nil {
+		t.Fatalf("non-empty set reported as empty: %v, %v", isEmpty, err)
+	}
+}
+
 func Test_Dot(t *testing.T) {
 	allMatch := []string{
 		"(?s)digraph docker {.*}",
@@ -62,7 +330,7 @@ func Test_Dot(t *testing.T) {
 
 		// TODO: test start image limiting
 
-		result := jsonToDot(roots, byParent)
+		result := jsonToDot(roots, byParent, DotOptions{})
 
 		for _, regexp := range allRegex {
 			if !regexp.MatchString(result) {
@@ -78,9 +346,271 @@ func Test_Dot(t *testing.T) {
 	}
 }
 
-func Test_Tree(t *testing.T) {
-	treeJSON := `[{"VirtualSize":674553464,"Size":2000000,"RepoTags":["foo:latest"],"ParentId":"735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Id":"c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470","Created":1386142123},{"VirtualSize":682553464,"Size":20000000,"RepoTags":["<none>:<none>"],"ParentId":"4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358","Id":"626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Created":1386142123},{"VirtualSize":712553464,"Size":30000000,"RepoTags":["base:latest"],"ParentId":"626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Id":"574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870","Created":1386142123},{"VirtualSize":752553464,"Size":40000000,"RepoTags":["<none>:<none>"],"ParentId":"574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870","Id":"aaf8d4d1bccab994574c5f626147582d2ae3735f5db5f2c87be8e5e697c08870","Created":1386142123},{"VirtualSize":672553464,"Size":10000000,"RepoTags":["<none>:<none>"],"ParentId":"4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358","Id":"735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470","Created":1386142123},{"VirtualSize":662553464,"Size":662553464,"RepoTags":["<none>:<none>"],"ParentId":"","Id":"4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358","Created":1386114144}]`
+func Test_DotAttrs(t *testing.T) {
+	attrs, err := parseDotAttrs([]string{"bgcolor=white", "ranksep=1.5", `label=say "hi"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	result := jsonToDot(collectRoots(im), collectChildren(im), DotOptions{GraphAttrs: attrs})
+
+	regexps := []string{
+		`^digraph docker {\n bgcolor="white"\n ranksep="1.5"\n label="say \\"hi\\""\n base -> `,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("images dot content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+
+	for _, invalid := range []string{"bgcolor", "=white", "bg color=white"} {
+		if _, err := parseDotAttrs([]string{invalid}); err == nil {
+			t.Errorf("invalid dot attribute '%s' did not cause an error", invalid)
+		}
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares output with testdata/name, rewriting the file instead
+// when -update is given.
+func checkGolden(t *testing.T, name string, output string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, []byte(output), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != string(expected) {
+		t.Fatalf("output did not match %s, got\n%s\nexpected\n%s", path, output, expected)
+	}
+}
+
+func Test_DotAllNodes(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+
+	checkGolden(t, "dot_all_nodes.golden", jsonToDot(roots, byParent, DotOptions{AllNodes: true}))
+}
+
+func Test_DotTagNodes(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["app:latest","app:1.0"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["db:latest"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	checkGolden(t, "dot_tag_nodes.golden", jsonToDot(collectRoots(im), collectChildren(im), DotOptions{TagNodes: true}))
+}
+
+func Test_DotMarkDangling(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+
+	checkGolden(t, "dot_mark_dangling.golden", jsonToDot(roots, byParent, DotOptions{MarkDangling: true}))
+
+	// dangling images are hidden unless all layers are shown, so the flag
+	// shows them
+	written, err := executeImages(t, ImagesCommand{Dot: true, MarkDangling: true, BaseName: "base", TruncLength: 12}, treeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(written, ` "aaf8d4d1bcca" [label="aaf8d4d1bcca",shape=box,fillcolor="lightgray",style="filled,dashed"];`) {
+		t.Fatalf("dangling image not marked by default in '%s'", written)
+	}
+}
+
+func Test_DotLabelsOnly(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, false)
+
+	checkGolden(t, "dot_labels_only.golden", jsonToDot(roots, byParent, DotOptions{LabelsOnly: true}))
+}
+
+func Test_DotBaseName(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, false)
+	result := jsonToDot(roots, byParent, DotOptions{BaseName: "base_host1"})
+
+	if !strings.Contains(result, ` base_host1 -> "4c1208b690c6" [style=invis]`) || !strings.Contains(result, " base_host1 [style=invisible]") {
+		t.Fatalf("custom base name missing from '%s'", result)
+	}
+	if regexp.MustCompile(`\bbase\b`).MatchString(strings.Replace(result, "base:latest", "", -1)) {
+		t.Fatalf("stray base node left in '%s'", result)
+	}
+}
+
+func Test_DotTitle(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, false)
+	checkGolden(t, "dot_title.golden", jsonToDot(roots, byParent, DotOptions{Title: "Build \"nightly\"\nC:\\images"}))
+
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	if title := autoTitle("tcp://build-host:2376", now); title != "Docker images on build-host, 2026-03-01 09:30" {
+		t.Fatalf("unexpected auto title '%s'", title)
+	}
+	hostname, _ := os.Hostname()
+	if title := autoTitle("unix:///var/run/docker.sock", now); title != "Docker images on "+hostname+", 2026-03-01 09:30" {
+		t.Fatalf("unexpected auto title '%s' for a local socket", title)
+	}
+}
+
+func Test_DotLabelEscaping(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["odd:say \"hi\"","odd:{a|b}<c>","odd:C:\\tmp"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+	roots, byParent := prepareTree(im, nil, false)
+
+	result := jsonToDot(roots, byParent, DotOptions{})
+	if !strings.Contains(result, ` "111111111111" [label="111111111111\nodd:say \"hi\"\nodd:{a|b}<c>\nodd:C:\\tmp",shape=box,`) {
+		t.Fatalf("tags not escaped in '%s'", result)
+	}
+
+	result = jsonToDot(roots, byParent, DotOptions{LabelShape: "record"})
+	if !strings.Contains(result, ` "111111111111" [label="{111111111111|odd:say\ \"hi\"|odd:\{a\|b\}\<c\>|odd:C:\\tmp}",shape=record,`) {
+		t.Fatalf("tags not escaped for records in '%s'", result)
+	}
+
+	result = jsonToDot(roots, byParent, DotOptions{TagNodes: true})
+	if !strings.Contains(result, ` "odd:say \"hi\"" [shape=ellipse];`) {
+		t.Fatalf("tag node names not escaped in '%s'", result)
+	}
+}
+
+func Test_DotRecordShape(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, false)
+	checkGolden(t, "dot_record.golden", jsonToDot(roots, byParent, DotOptions{LabelShape: "record"}))
+}
+
+func Test_DotFullIdTitle(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+	result := jsonToDot(roots, byParent, DotOptions{FullIdTitle: true})
+
+	for _, image := range *im {
+		full := regexp.QuoteMeta(image.Id)
+		if !regexp.MustCompile(`(?m)^ "` + truncate(image.Id) + `" \[id="` + full + `",tooltip="` + full + `"\];$`).MatchString(result) {
+			t.Errorf("full id of %s missing from '%s'", truncate(image.Id), result)
+		}
+	}
+	if !strings.Contains(result, ` "c87be8e5e697" [label="c87be8e5e697\nfoo:latest",`) {
+		t.Fatalf("label no longer truncated in '%s'", result)
+	}
+}
+
+func Test_DotConcentrate(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, false)
+	checkGolden(t, "dot_concentrate.golden", jsonToDot(roots, byParent, DotOptions{Concentrate: true}))
+
+	if dot := jsonToDot(roots, byParent, DotOptions{}); strings.Contains(dot, "concentrate") {
+		t.Fatalf("concentrate set by default in '%s'", dot)
+	}
+}
+
+func Test_DotMaxNodes(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["base:latest"],"VirtualSize":100},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["big:latest"],"VirtualSize":900},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["small:latest"],"VirtualSize":200},{"Id":"4444444444444444","RepoTags":["alpine:3"],"VirtualSize":50},{"Id":"5555555555555555","ParentId":"2222222222222222","RepoTags":["bigger:latest"],"VirtualSize":1000}]`
+	im, _ := parseImagesJSON([]byte(json))
+	roots, byParent := prepareTree(im, nil, false)
+
+	roots, byParent, notShown := capNodes(roots, byParent, 2)
+	var ids []string
+	for _, image := range flattenTree(roots, byParent) {
+		ids = append(ids, image.Id[:4])
+	}
+	if strings.Join(ids, " ") != "1111 2222 5555" || notShown != 2 {
+		t.Fatalf("kept %v and left out %d, expected the two largest with their base and 2 left out", ids, notShown)
+	}
 
+	dot := jsonToDot(roots, byParent, DotOptions{NotShown: notShown})
+	if !strings.Contains(dot, ` not_shown [label="(+2 images not shown)",shape=note];`) {
+		t.Fatalf("notice node missing from '%s'", dot)
+	}
+	if dot := jsonToDot(roots, byParent, DotOptions{NotShown: 1}); !strings.Contains(dot, `label="(+1 image not shown)"`) {
+		t.Fatalf("single image left out was noted as '%s'", dot)
+	}
+	if strings.Contains(dot, "333333333333") || strings.Contains(dot, "444444444444") {
+		t.Fatalf("small images were drawn in '%s'", dot)
+	}
+
+	if _, _, notShown := capNodes(roots, byParent, 10); notShown != 0 {
+		t.Fatalf("capped a tree under the limit, leaving out %d", notShown)
+	}
+}
+
+func Test_DotColorByAge(t *testing.T) {
+	json := `[{"VirtualSize":10,"Size":10,"RepoTags":["old:latest"],"ParentId":"","Id":"0ld0000000000000000000000000000000000000000000000000000000000000","Created":1386114144},{"VirtualSize":20,"Size":10,"RepoTags":["new:latest"],"ParentId":"0ld0000000000000000000000000000000000000000000000000000000000000","Id":"new0000000000000000000000000000000000000000000000000000000000000","Created":1486114144},{"VirtualSize":30,"Size":10,"RepoTags":["undated:latest"],"ParentId":"0ld0000000000000000000000000000000000000000000000000000000000000","Id":"und0000000000000000000000000000000000000000000000000000000000000","Created":0}]`
+
+	im, _ := parseImagesJSON([]byte(json))
+	result := jsonToDot(collectRoots(im), collectChildren(im), DotOptions{ColorByAge: true})
+
+	fillcolor := regexp.MustCompile(`"(\w{12})" \[label=[^\]]*fillcolor="([^"]+)"`)
+	colors := make(map[string]string)
+	for _, match := range fillcolor.FindAllStringSubmatch(result, -1) {
+		colors[match[1]] = match[2]
+	}
+
+	if colors["new000000000"] != "#66cc66" {
+		t.Fatalf("newest image should be green, got '%s' in '%s'", colors["new000000000"], result)
+	}
+	if colors["0ld000000000"] != "#bbbbbb" {
+		t.Fatalf("oldest image should be gray, got '%s' in '%s'", colors["0ld000000000"], result)
+	}
+	if colors["und000000000"] != "white" {
+		t.Fatalf("undated image should be neutral, got '%s' in '%s'", colors["und000000000"], result)
+	}
+}
+
+func Test_DotLegend(t *testing.T) {
+	json := `[{"VirtualSize":10,"Size":10,"RepoTags":["old:latest"],"ParentId":"","Id":"0ld0000000000000000000000000000000000000000000000000000000000000","Created":1386114144},{"VirtualSize":20,"Size":10,"RepoTags":["new:latest"],"ParentId":"0ld0000000000000000000000000000000000000000000000000000000000000","Id":"new0000000000000000000000000000000000000000000000000000000000000","Created":1486114144}]`
+
+	im, _ := parseImagesJSON([]byte(json))
+	checkGolden(t, "dot_legend.golden", jsonToDot(collectRoots(im), collectChildren(im), DotOptions{ColorByAge: true, Legend: true}))
+}
+
+func Test_RecentSince(t *testing.T) {
+	json := `[{"VirtualSize":10,"RepoTags":["old:latest"],"ParentId":"","Id":"0ld0000000000000","Created":1386114144},{"VirtualSize":20,"RepoTags":["new:latest"],"ParentId":"0ld0000000000000","Id":"new0000000000000","Created":1486114144}]`
+	since := time.Unix(1486000000, 0)
+
+	im, _ := parseImagesJSON([]byte(json))
+	dot := jsonToDot(collectRoots(im), collectChildren(im), DotOptions{RecentSince: since})
+	if !strings.Contains(dot, `"new000000000" [label="new000000000\nnew:latest",shape=box,fillcolor="gold"`) {
+		t.Fatalf("newer image not highlighted in '%s'", dot)
+	}
+	if !strings.Contains(dot, `"0ld000000000" [label="0ld000000000\nold:latest",shape=box,fillcolor="gray90"`) {
+		t.Fatalf("older image not dimmed in '%s'", dot)
+	}
+
+	tree := jsonToTree(collectRoots(im), collectChildren(im), TreeOptions{NoSizeLabel: true, RecentSince: since})
+	expected := "└─\x1b[2m0ld000000000 10.0 B Tags: old:latest\x1b[0m\n" +
+		"  └─\x1b[1mnew000000000 20.0 B Tags: new:latest\x1b[0m\n"
+	if tree != expected {
+		t.Fatalf("tree was %q, expected %q", tree, expected)
+	}
+
+	// redirected output stays plain
+	originalIsTerminal := isTerminal
+	defer func() { isTerminal = originalIsTerminal }()
+	command := ImagesCommand{Tree: true, RecentSince: 24 * time.Hour, NoSizeLabel: true, SizeFormat: "si", TruncLength: 12}
+	written, err := executeImages(t, command, json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(written, "\x1b") {
+		t.Fatalf("escapes written to a file: %q", written)
+	}
+	isTerminal = func(fd int) bool { return true }
+	if written, _ = executeImages(t, command, json); !strings.Contains(written, "\x1b[2m") {
+		t.Fatalf("no escapes written to a terminal: %q", written)
+	}
+}
+
+func Test_Tree(t *testing.T) {
 	treeTests := []TreeTest{
 		TreeTest{
 			json:       treeJSON,
@@ -138,7 +668,7 @@ func Test_Tree(t *testing.T) {
 		} else {
 			roots = collectRoots(im)
 		}
-		result := jsonToTree(roots, byParent, treeTest.noTrunc, treeTest.incr)
+		result := jsonToTree(roots, byParent, TreeOptions{NoTruncate: treeTest.noTrunc, Incremental: treeTest.incr})
 
 		for _, regexp := range compileRegexps(t, treeTest.regexps) {
 			if !regexp.MatchString(result) {
@@ -148,28 +678,1114 @@ func Test_Tree(t *testing.T) {
 	}
 }
 
-func Test_Short(t *testing.T) {
-	shortJSON := `[ { "VirtualSize": 662553464, "Size": 0, "RepoTags": [ "foo:latest" ], "ParentId": "735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Id": "c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470", "Created": 1386142123 }, { "VirtualSize": 682553464, "Size": 0, "RepoTags": [ "foo:1.0" ], "ParentId": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Id": "626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Created": 1386142123 }, { "VirtualSize": 712553464, "Size": 0, "RepoTags": [ "foo:2.0" ], "ParentId": "626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Id": "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", "Created": 1386142123 }, { "VirtualSize": 752553464, "Size": 0, "RepoTags": [ "private.repo.com:5000:latest" ], "ParentId": "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", "Id": "aaf8d4d1bccab994574c5f626147582d2ae3735f5db5f2c87be8e5e697c08870", "Created": 1386142123 }, { "VirtualSize": 662553464, "Size": 0, "RepoTags": [ "<none>:<none>" ], "ParentId": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Id": "735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Created": 1386142123 }, { "VirtualSize": 662553464, "Size": 662553464, "RepoTags": [ "<none>:<none>" ], "ParentId": "", "Id": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Created": 1386114144 } ]`
-
-	shortTests := []ShortTest{
-		ShortTest{
-			json: shortJSON,
+func Test_TreeLayers(t *testing.T) {
+	layerTests := []struct {
+		allLayers bool
+		regexps   []string
+		absent    []string
+	}{
+		{
+			allLayers: false,
 			regexps: []string{
-				`(?m)foo: latest, 1.0, 2.0`,
-				`(?m)private.repo.com:5000: latest`,
+				`(?m)^└─4c1208b690c6`,
+				`(?m)^  ├─c87be8e5e697 .*Tags: foo:latest`,
+				`(?m)^  └─574c5faaf8d4 .*Tags: base:latest`,
+			},
+			absent: []string{
+				`735f5db56261`,
+				`626147582d2a`,
+				`aaf8d4d1bcca`,
+			},
+		},
+		{
+			allLayers: true,
+			regexps: []string{
+				`(?m)^└─4c1208b690c6`,
+				`(?m)^  ├─626147582d2a`,
+				`(?m)^  └─735f5db56261`,
+				`(?m)^  │   └─aaf8d4d1bcca`,
 			},
 		},
 	}
 
-	for _, shortTest := range shortTests {
-		im, _ := parseImagesJSON([]byte(shortTest.json))
-		result := jsonToShort(im)
+	for _, layerTest := range layerTests {
+		im, _ := parseImagesJSON([]byte(treeJSON))
+		roots, byParent := prepareTree(im, nil, layerTest.allLayers)
+		result := jsonToTree(roots, byParent, TreeOptions{})
 
-		for _, regexp := range compileRegexps(t, shortTest.regexps) {
+		for _, regexp := range compileRegexps(t, layerTest.regexps) {
 			if !regexp.MatchString(result) {
-				t.Fatalf("images short content '%s' did not match regexp '%s'", result, regexp)
+				t.Fatalf("images tree content '%s' did not match regexp '%s'", result, regexp)
 			}
 		}
+		for _, regexp := range compileRegexps(t, layerTest.absent) {
+			if regexp.MatchString(result) {
+				t.Fatalf("images tree content '%s' unexpectedly matched regexp '%s'", result, regexp)
+			}
+		}
+	}
+}
+
+func Test_NoSizeLabel(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, false)
+
+	result := jsonToTree(roots, byParent, TreeOptions{})
+	if !regexp.MustCompile(`(?m)^  ├─c87be8e5e697 Virtual Size: 674.6 MB Tags: foo:latest$`).MatchString(result) {
+		t.Fatalf("size label missing by default in '%s'", result)
+	}
+
+	result = jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true})
+	if !regexp.MustCompile(`(?m)^  ├─c87be8e5e697 674.6 MB Tags: foo:latest$`).MatchString(result) {
+		t.Fatalf("unexpected tree line in '%s'", result)
+	}
+	if strings.Contains(result, "Virtual Size") {
+		t.Fatalf("size label present in '%s'", result)
+	}
+}
+
+func Test_LayerCount(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	opts := TreeOptions{LayerCounts: layerCounts(*im)}
+	roots, byParent := prepareTree(im, nil, true)
+	result := jsonToTree(roots, byParent, opts)
+
+	regexps := []string{
+		`(?m)└─4c1208b690c6 Virtual Size: 662.6 MB$`,
+		`(?m)c87be8e5e697 .* Tags: foo:latest \[3 layers\]$`,
+		`(?m)574c5faaf8d4 .* Tags: base:latest \[3 layers\]$`,
+		`(?m)aaf8d4d1bcca Virtual Size: 752.6 MB$`,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("images tree content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+
+	// counts reflect the full lineage even once intermediates are hidden
+	im, _ = parseImagesJSON([]byte(`[{"Id":"a0000000000000","ParentId":"","RepoTags":["a:latest"]},{"Id":"b0000000000000","ParentId":"a0000000000000","RepoTags":["<none>:<none>"]},{"Id":"c0000000000000","ParentId":"b0000000000000","RepoTags":["<none>:<none>"]},{"Id":"d0000000000000","ParentId":"c0000000000000","RepoTags":["d:latest"]}]`))
+	opts = TreeOptions{LayerCounts: layerCounts(*im)}
+	roots, byParent = prepareTree(im, nil, false)
+	result = jsonToTree(roots, byParent, opts)
+
+	regexps = []string{
+		`(?m)^└─a00000000000 .* Tags: a:latest \[1 layer\]$`,
+		`(?m)^  └─d00000000000 .* Tags: d:latest \[4 layers\]$`,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("images tree content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+}
+
+func Test_SinceImage(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+
+	base, _ := findStartImage("4c1208b690c6", im)
+	target, _ := findStartImage("aaf8d4d1bcca", im)
+	added, err := layersSince(base, target, *im, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots, byParent := chainToTree(added)
+	result := jsonToTree(roots, byParent, TreeOptions{Incremental: true}) + sinceSummary(added, "si")
+
+	regexps := []string{
+		`(?m)^└─626147582d2a Virtual Size: 20.0 MB$`,
+		`(?m)^  └─574c5faaf8d4 Virtual Size: 30.0 MB Tags: base:latest$`,
+		`(?m)^    └─aaf8d4d1bcca Virtual Size: 40.0 MB$`,
+		`(?m)^3 layers added, 90.0 MB in total$`,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("since-image content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+	for _, absent := range []string{"4c1208b690c6", "735f5db56261", "c87be8e5e697"} {
+		if strings.Contains(result, absent) {
+			t.Fatalf("since-image content '%s' should not contain '%s'", result, absent)
+		}
+	}
+
+	// an image from another branch isn't a base of the target
+	other, _ := findStartImage("foo:latest", im)
+	if _, err := layersSince(other, target, *im, 12); err == nil {
+		t.Fatal("expected an error for a base that isn't an ancestor")
+	}
+
+	if summary := sinceSummary(added[2:], "si"); summary != "1 layer added, 40.0 MB in total\n" {
+		t.Fatalf("unexpected summary for one layer '%s'", summary)
+	}
+}
+
+func Test_InferParents(t *testing.T) {
+	json := `[{"Id":"app2000000000000","RepoTags":["app:2.0"],"Created":300},{"Id":"app1000000000000","RepoTags":["app:1.0"],"Created":200},{"Id":"base000000000000","RepoTags":["base:latest"],"Created":100},{"Id":"app3000000000000","RepoTags":["app:3.0"],"Created":400,"History":[{"Id":"app3000000000000"},{"Id":"<missing>"},{"Id":"base000000000000"}]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	if !inferParents(*im) {
+		t.Fatal("expected parents to be inferred")
+	}
+
+	roots, byParent := prepareTree(im, nil, true)
+	result := jsonToTree(roots, byParent, TreeOptions{})
+	expected := `├─app100000000 Virtual Size: 0.0 B Tags: app:1.0
+│ └─app200000000 Virtual Size: 0.0 B Tags: app:2.0 (inferred parent)
+└─base00000000 Virtual Size: 0.0 B Tags: base:latest
+  └─app300000000 Virtual Size: 0.0 B Tags: app:3.0 (inferred parent)
+`
+	if result != expected {
+		t.Fatalf("inferred tree was\n%s\nexpected\n%s", result, expected)
+	}
+
+	dot := jsonToDot(roots, byParent, DotOptions{})
+	if !strings.Contains(dot, `"app100000000" -> "app200000000" [style=dashed]`) {
+		t.Fatalf("inferred edge not dashed in '%s'", dot)
+	}
+
+	// real parent ids are left alone
+	im, _ = parseImagesJSON([]byte(treeJSON))
+	if inferParents(*im) {
+		t.Fatal("parents inferred although parent ids were present")
+	}
+}
+
+func Test_TruncationCollisions(t *testing.T) {
+	json := `[{"Id":"abcdefabcdef1111","ParentId":"","RepoTags":["app:latest"]},{"Id":"abcdefabcdef2222","ParentId":"abcdefabcdef1111","RepoTags":["db:latest"]},{"Id":"0123456789ab","ParentId":"","RepoTags":["short:latest"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	var warnings bytes.Buffer
+	length, err := checkTruncation(*im, 12, &warnings, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length != 13 {
+		t.Fatalf("ids truncated to %d characters, expected 13", length)
+	}
+	if warnings.String() != "Warning: showing 13 characters of image ids, as 12 would not keep them distinct\n" {
+		t.Fatalf("unexpected warning '%s'", warnings.String())
+	}
+
+	result := jsonToTree(collectRoots(im), collectChildren(im), TreeOptions{NoSizeLabel: true, IdLength: length})
+	expected := `├─abcdefabcdef1 0.0 B Tags: app:latest
+│ └─abcdefabcdef2 0.0 B Tags: db:latest
+└─0123456789ab 0.0 B Tags: short:latest
+`
+	if result != expected {
+		t.Fatalf("colliding ids rendered as\n%s\nexpected\n%s", result, expected)
+	}
+
+	dot := jsonToDot(collectRoots(im), collectChildren(im), DotOptions{IdLength: length})
+	if !strings.Contains(dot, `"abcdefabcdef1" -> "abcdefabcdef2"`) {
+		t.Fatalf("colliding ids merged in dot output '%s'", dot)
+	}
+
+	if _, err := checkTruncation(*im, 4, &bytes.Buffer{}, true); err == nil {
+		t.Fatal("collision did not cause an error in strict mode")
+	}
+
+	warnings.Reset()
+	im, _ = parseImagesJSON([]byte(treeJSON))
+	length, err = checkTruncation(*im, 6, &warnings, false)
+	if err != nil || warnings.Len() > 0 || length != 6 {
+		t.Fatalf("distinct ids were lengthened: %v '%s' %d", err, warnings.String(), length)
+	}
+}
+
+func Test_NewerThan(t *testing.T) {
+	now := time.Unix(1386142123+3600, 0)
+	im, _ := parseImagesJSON([]byte(treeJSON))
+
+	recent := newerThan(*im, now, 2*time.Hour)
+	if len(recent) != 5 {
+		t.Fatalf("expected the 5 images from the last 2 hours, got %d", len(recent))
+	}
+	for _, image := range recent {
+		if truncate(image.Id) == "4c1208b690c6" {
+			t.Fatal("old root image was not filtered out")
+		}
+	}
+
+	// the survivors' parent is gone, so they become roots
+	promoted := promoteOrphans(recent, false)
+	if roots := collectRoots(&promoted); len(roots) != 2 {
+		t.Fatalf("expected 2 roots after filtering, got %d", len(roots))
+	}
+
+	if len(newerThan(*im, now, time.Minute)) != 0 {
+		t.Fatal("images older than the cutoff were kept")
+	}
+}
+
+func Test_NewerThanDaemon(t *testing.T) {
+	now := time.Now()
+	listed := stubImageLister{
+		{ID: "1111111111111111", Created: now.Add(-time.Hour).Unix(), RepoTags: []string{"recent:latest"}},
+		{ID: "2222222222222222", ParentID: "1111111111111111", Created: now.Add(-10 * time.Minute).Unix(), RepoTags: []string{"child:latest"}},
+		{ID: "3333333333333333", Created: now.Add(-48 * time.Hour).Unix(), RepoTags: []string{"old:latest"}},
+		{ID: "4444444444444444", ParentID: "3333333333333333", Created: now.Add(-30 * time.Minute).Unix(), RepoTags: []string{"rebuilt:latest"}},
+	}
+
+	written, err := executeDaemonImages(t, ImagesCommand{Tree: true, NewerThan: 2 * time.Hour, NoSizeLabel: true, SizeFormat: "si", TruncLength: 12}, listed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `├─111111111111 0.0 B Tags: recent:latest
+│ └─222222222222 0.0 B Tags: child:latest
+└─444444444444 0.0 B Tags: rebuilt:latest
+`
+	if written != expected {
+		t.Fatalf("recent daemon images rendered as\n%s\nexpected\n%s", written, expected)
+	}
+}
+
+func Test_Orphans(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"gone","RepoTags":["app:latest"]},{"Id":"2222222222222222","ParentId":"gone","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"gone","RepoTags":["<none>:<none>"]},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["db:latest"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	promoted := promoteOrphans(*im, false)
+	roots, byParent := prepareTree(&promoted, nil, false)
+	if result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true}); result != `├─111111111111 0.0 B Tags: app:latest
+├─222222222222 0.0 B
+└─333333333333 0.0 B
+  └─444444444444 0.0 B Tags: db:latest
+` {
+		t.Fatalf("orphans were not promoted to roots: '%s'", result)
+	}
+
+	hidden := promoteOrphans(*im, true)
+	roots, byParent = prepareTree(&hidden, nil, false)
+	if result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true}); result != `├─111111111111 0.0 B Tags: app:latest
+└─333333333333 0.0 B
+  └─444444444444 0.0 B Tags: db:latest
+` {
+		t.Fatalf("childless untagged orphan was not hidden: '%s'", result)
+	}
+}
+
+func Test_DepthPerRoot(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["nginx-base:latest"]},{"Id":"1222222222222222","ParentId":"1111111111111111","RepoTags":["nginx:latest"]},{"Id":"1333333333333333","ParentId":"1222222222222222","RepoTags":["web:1"]},{"Id":"1444444444444444","ParentId":"1333333333333333","RepoTags":["web:2"]},{"Id":"2111111111111111","RepoTags":["ubuntu:latest"]},{"Id":"2222222222222222","ParentId":"2111111111111111","RepoTags":["app:1"]},{"Id":"2333333333333333","ParentId":"2222222222222222","RepoTags":["app:2"]},{"Id":"3111111111111111","RepoTags":["alpine:3"]},{"Id":"3222222222222222","ParentId":"3111111111111111","RepoTags":["tool:1"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+	roots, byParent := prepareTree(im, nil, false)
+
+	depths, err := parseRootDepths("nginx=2,ubuntu=3", roots, byParent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true, Depth: 1, RootDepths: depths})
+	expected := `├─111111111111 0.0 B Tags: nginx-base:latest
+│ └─122222222222 0.0 B Tags: nginx:latest
+│   └─133333333333 0.0 B Tags: web:1
+├─211111111111 0.0 B Tags: ubuntu:latest
+│ └─222222222222 0.0 B Tags: app:1
+│   └─233333333333 0.0 B Tags: app:2
+└─311111111111 0.0 B Tags: alpine:3
+  └─322222222222 0.0 B Tags: tool:1
+`
+	if result != expected {
+		t.Fatalf("depth limited tree was\n%s\nexpected\n%s", result, expected)
+	}
+
+	for _, spec := range []string{"nginx", "nginx=0", "nginx=two"} {
+		if _, err := parseRootDepths(spec, roots, byParent); err == nil {
+			t.Errorf("expected an error for '%s'", spec)
+		}
+	}
+}
+
+func Test_TreePercent(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["base:latest"],"VirtualSize":600,"Size":600},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"VirtualSize":650,"Size":50},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest"],"VirtualSize":900,"Size":250},{"Id":"4444444444444444","RepoTags":["tool:1"],"VirtualSize":100,"Size":100}]`
+	im, _ := parseImagesJSON([]byte(json))
+	total := computeStats(*im).TotalIncrementalSize
+	roots, byParent := prepareTree(im, nil, true)
+
+	result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true, Incremental: true, PercentOf: total})
+	expected := `├─111111111111 600.0 B (60.0%) Tags: base:latest
+│ └─222222222222 50.0 B (5.0%)
+│   └─333333333333 250.0 B (25.0%) Tags: app:latest
+└─444444444444 100.0 B (10.0%) Tags: tool:1
+`
+	if result != expected {
+		t.Fatalf("tree with percentages was\n%s\nexpected\n%s", result, expected)
+	}
+
+	var sum float64
+	for _, match := range regexp.MustCompile(`\(([\d.]+)%\)`).FindAllStringSubmatch(result, -1) {
+		share, _ := strconv.ParseFloat(match[1], 64)
+		sum += share
+	}
+	if sum < 99.9 || sum > 100.1 {
+		t.Fatalf("incremental shares add up to %.1f%%", sum)
+	}
+
+	// next to virtual sizes the shares are still of the images' own sizes
+	result = jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true, PercentOf: total})
+	if !strings.Contains(result, "333333333333 900.0 B (25.0%) Tags: app:latest") {
+		t.Fatalf("unexpected virtual size share in '%s'", result)
+	}
+	sum = 0
+	for _, match := range regexp.MustCompile(`\(([\d.]+)%\)`).FindAllStringSubmatch(result, -1) {
+		share, _ := strconv.ParseFloat(match[1], 64)
+		sum += share
+	}
+	if sum < 99.9 || sum > 100.1 {
+		t.Fatalf("shares next to virtual sizes add up to %.1f%%", sum)
+	}
+
+	// without --all-layers the total only covers the images shown
+	written, err := executeImages(t, ImagesCommand{Tree: true, Percent: true, Incremental: true, NoSizeLabel: true, SizeFormat: "si", TruncLength: 12}, json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `├─111111111111 600.0 B (63.2%) Tags: base:latest
+│ └─333333333333 250.0 B (26.3%) Tags: app:latest
+└─444444444444 100.0 B (10.5%) Tags: tool:1
+`
+	if written != expected {
+		t.Fatalf("tree with percentages of the images shown was\n%s\nexpected\n%s", written, expected)
+	}
+
+	written, _ = executeImages(t, ImagesCommand{Tree: true, Percent: true, NoSizeLabel: true, SizeFormat: "si", TruncLength: 12}, json)
+	if !strings.Contains(written, "333333333333 900.0 B (26.3%) Tags: app:latest") {
+		t.Fatalf("unexpected shares next to virtual sizes in '%s'", written)
+	}
+}
+
+func Test_CollapseToTagged(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["<none>:<none>"],"Size":5000},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["base:latest"],"Size":100},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["<none>:<none>"],"Size":10},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["<none>:<none>"],"Size":20},{"Id":"5555555555555555","ParentId":"4444444444444444","RepoTags":["app:latest"],"Size":1},{"Id":"6666666666666666","ParentId":"2222222222222222","RepoTags":["tool:1"],"Size":2},{"Id":"7777777777777777","ParentId":"6666666666666666","RepoTags":["<none>:<none>"],"Size":30},{"Id":"8888888888888888","ParentId":"7777777777777777","RepoTags":["tool:2"],"Size":3}]`
+	im, _ := parseImagesJSON([]byte(json))
+	roots, byParent, skipped := collapseToTagged(*im, "si")
+
+	result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true, Incremental: true, Skipped: skipped})
+	expected := `└─222222222222 100.0 B Tags: base:latest
+  ├─555555555555 1.0 B Tags: app:latest (via 2 layers, 30.0 B)
+  └─666666666666 2.0 B Tags: tool:1
+    └─888888888888 3.0 B Tags: tool:2 (via 1 layer, 30.0 B)
+`
+	if result != expected {
+		t.Fatalf("collapsed tree was\n%s\nexpected\n%s", result, expected)
+	}
+
+	dot := jsonToDot(roots, byParent, DotOptions{Skipped: skipped})
+	for _, edge := range []string{
+		` "222222222222" -> "555555555555" [label="2 layers, 30.0 B"]`,
+		` "222222222222" -> "666666666666"` + "\n",
+		` "666666666666" -> "888888888888" [label="1 layer, 30.0 B"]`,
+	} {
+		if !strings.Contains(dot, edge) {
+			t.Errorf("edge '%s' missing from '%s'", edge, dot)
+		}
+	}
+	if strings.Contains(dot, "333333333333") || strings.Contains(dot, "111111111111") {
+		t.Fatalf("untagged images drawn in '%s'", dot)
+	}
+}
+
+func Test_ShortNames(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["registry.corp/base/debian:12"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["registry.corp/team/app:latest","localhost:5000/app:dev"]},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["team/tool:1"]},{"Id":"4444444444444444","RepoTags":["alpine:3"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	// patterns and start images still match the full names
+	selected := selectImages(*im, []string{"registry.corp/team/*"}, nil)
+	start, err := findStartImage("registry.corp/base/debian:12", &selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, byParent := prepareTree(&selected, start, false)
+	relabelTree(roots, byParent, shortTags(flattenTree(roots, byParent), false))
+	expected := `└─111111111111 0.0 B Tags: base/debian:12
+  └─222222222222 0.0 B Tags: team/app:latest, app:dev
+`
+	if result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true}); result != expected {
+		t.Fatalf("tree with short names was\n%s\nexpected\n%s", result, expected)
+	}
+
+	result := jsonToShort(relabelImages(*im, shortTags(*im, true)), ShortOptions{})
+	expected = `alpine: 3
+app: latest, dev
+debian: 12
+tool: 1
+`
+	if result != expected {
+		t.Fatalf("short output without namespaces was\n%s\nexpected\n%s", result, expected)
+	}
+	if (*im)[1].RepoTags[0] != "registry.corp/team/app:latest" {
+		t.Fatal("relabeling changed the full names")
+	}
+}
+
+func Test_DropEmpty(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["scratch:latest"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest"],"Size":100,"VirtualSize":100},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["<none>:<none>"]},{"Id":"5555555555555555","ParentId":"4444444444444444","RepoTags":["app:2"],"Size":50,"VirtualSize":150},{"Id":"6666666666666666","ParentId":"3333333333333333","RepoTags":["<none>:<none>"],"VirtualSize":100}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	kept := dropEmpty(*im)
+	roots, byParent := prepareTree(&kept, nil, true)
+	expected := `└─333333333333 100.0 B Tags: app:latest
+  ├─555555555555 150.0 B Tags: app:2
+  └─666666666666 100.0 B
+`
+	if result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true}); result != expected {
+		t.Fatalf("tree without empty images was\n%s\nexpected\n%s", result, expected)
+	}
+	if kept[1].ParentId != "3333333333333333" {
+		t.Fatalf("child of an empty image reparented onto '%s'", kept[1].ParentId)
+	}
+}
+
+func Test_SkipUntaggedRoots(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"gone","RepoTags":["app:latest"]},{"Id":"2222222222222222","ParentId":"gone","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"gone","RepoTags":["<none>:<none>"]},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["db:latest"]},{"Id":"5555555555555555","ParentId":"4444444444444444","RepoTags":["<none>:<none>"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	promoted := promoteOrphans(*im, false)
+	roots, byParent := prepareTree(&promoted, nil, true)
+	roots = skipUntaggedRoots(roots, byParent)
+	if result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true}); result != `├─111111111111 0.0 B Tags: app:latest
+└─444444444444 0.0 B Tags: db:latest
+  └─555555555555 0.0 B
+` {
+		t.Fatalf("untagged roots were not skipped: '%s'", result)
+	}
+	if dot := jsonToDot(roots, byParent, DotOptions{}); strings.Contains(dot, "333333333333") {
+		t.Fatalf("skipped root still drawn in '%s'", dot)
+	}
+
+	// two untagged levels above the first tagged image
+	json = `[{"Id":"1111111111111111","ParentId":"","RepoTags":["<none>:<none>"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest"]},{"Id":"4444444444444444","ParentId":"2222222222222222","RepoTags":["web:latest"]}]`
+	im, _ = parseImagesJSON([]byte(json))
+	roots, byParent = prepareTree(im, nil, true)
+	roots = skipUntaggedRoots(roots, byParent)
+	if result := jsonToTree(roots, byParent, TreeOptions{NoSizeLabel: true}); result != `├─333333333333 0.0 B Tags: app:latest
+└─444444444444 0.0 B Tags: web:latest
+` {
+		t.Fatalf("nested untagged roots were not skipped: '%s'", result)
+	}
+}
+
+func Test_OrphanSummary(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"gone","RepoTags":["app:latest"],"Size":300},{"Id":"2222222222222222","ParentId":"","RepoTags":["base:latest"],"Size":500},{"Id":"3333333333333333","ParentId":"missing","RepoTags":["<none>:<none>"],"Size":200},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["db:latest"],"Size":400}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	if result := orphanSummary(*im, "si"); result != "Orphans: 500.0 B in 2 images whose parent is missing\n" {
+		t.Fatalf("unexpected orphan summary '%s'", result)
+	}
+}
+
+func Test_RenameRepos(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["oldregistry/app:1.0","oldregistry/app:latest"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["other/oldregistry:2"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+	renames, err := parseRenames([]string{"oldregistry/=newregistry/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := renameRepos(*im, renames)
+
+	if (*im)[0].RepoTags[0] != "oldregistry/app:1.0" {
+		t.Fatal("renaming changed the original images")
+	}
+
+	roots, byParent := prepareTree(&renamed, nil, false)
+	var nodes bytes.Buffer
+	writeTreeJSON(&nodes, roots, byParent, TreeOptions{}, false)
+	outputs := map[string]string{
+		"tree":  jsonToTree(roots, byParent, TreeOptions{}),
+		"dot":   jsonToDot(roots, byParent, DotOptions{}),
+		"short": jsonToShort(&renamed, ShortOptions{}),
+		"json":  nodes.String(),
+	}
+	for renderer, output := range outputs {
+		if !strings.Contains(output, "newregistry/app") || strings.Contains(output, "oldregistry/app") {
+			t.Errorf("%s output was not renamed: '%s'", renderer, output)
+		}
+		if !strings.Contains(output, "other/oldregistry") {
+			t.Errorf("%s output renamed a repository not starting with the prefix: '%s'", renderer, output)
+		}
+	}
+
+	if _, err := parseRenames([]string{"nonsense"}); err == nil {
+		t.Fatal("expected an error for a rename without =")
+	}
+
+	// the start image can be named by its old or its new tag
+	for _, start := range []string{"oldregistry/app:1.0", "newregistry/app:1.0"} {
+		written, err := executeImages(t, ImagesCommand{Tree: true, Rename: []string{"oldregistry/=newregistry/"}, NoSizeLabel: true, SizeFormat: "si", TruncLength: 12}, json, start)
+		expected := "└─111111111111 0.0 B Tags: newregistry/app:1.0, newregistry/app:latest\n  └─222222222222 0.0 B Tags: other/oldregistry:2\n"
+		if err != nil || written != expected {
+			t.Errorf("tree from %s was\n%s\nexpected\n%s (%v)", start, written, expected, err)
+		}
+	}
+}
+
+func Test_LatestOnly(t *testing.T) {
+	json := `[{"Id":"app1000000000000","ParentId":"base000000000000","RepoTags":["app:1.0","app:latest"]},{"Id":"app2000000000000","ParentId":"base000000000000","RepoTags":["app:2.0"]},{"Id":"base000000000000","RepoTags":["base:3.1","base:3.2"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	roots, byParent := prepareTree(im, nil, false)
+	relabelTree(roots, byParent, latestTags(flattenTree(roots, byParent)))
+	result := jsonToTree(roots, byParent, TreeOptions{})
+	expected := `└─base00000000 Virtual Size: 0.0 B Tags: base:3.2
+  ├─app100000000 Virtual Size: 0.0 B Tags: app:latest
+  └─app200000000 Virtual Size: 0.0 B
+`
+	if result != expected {
+		t.Fatalf("latest-only tree was\n%s\nexpected\n%s", result, expected)
+	}
+	if len((*im)[0].RepoTags) != 2 {
+		t.Fatal("relabelling changed the underlying images")
+	}
+}
+
+func Test_SelectImages(t *testing.T) {
+	selectTests := []struct {
+		includes []string
+		excludes []string
+		expected []string
+	}{
+		// a single include keeps the image and its ancestors
+		{
+			includes: []string{"foo:*"},
+			expected: []string{"c87be8e5e697", "735f5db56261", "4c1208b690c6"},
+		},
+		// multiple includes, by tag and by id prefix
+		{
+			includes: []string{"foo", "574c5faaf8d4"},
+			expected: []string{"c87be8e5e697", "626147582d2a", "574c5faaf8d4", "735f5db56261", "4c1208b690c6"},
+		},
+		// excludes drop the whole subtree and win over includes
+		{
+			includes: []string{"foo:latest", "base:latest"},
+			excludes: []string{"626147582d2a"},
+			expected: []string{"c87be8e5e697", "735f5db56261", "4c1208b690c6"},
+		},
+		{
+			includes: []string{"base:*"},
+			excludes: []string{"base"},
+			expected: []string{"626147582d2a", "4c1208b690c6"},
+		},
+		// excludes alone
+		{
+			excludes: []string{"735f5db56261"},
+			expected: []string{"626147582d2a", "574c5faaf8d4", "aaf8d4d1bcca", "4c1208b690c6"},
+		},
+	}
+
+	for _, selectTest := range selectTests {
+		im, _ := parseImagesJSON([]byte(treeJSON))
+		selected := selectImages(*im, selectTest.includes, selectTest.excludes)
+
+		var ids []string
+		for _, image := range selected {
+			ids = append(ids, truncate(image.Id))
+		}
+		if strings.Join(ids, ",") != strings.Join(selectTest.expected, ",") {
+			t.Fatalf("include %v exclude %v selected %v, expected %v", selectTest.includes, selectTest.excludes, ids, selectTest.expected)
+		}
+	}
+}
+
+func Test_FilterFile(t *testing.T) {
+	includes, excludes, err := readFilterFile("testdata/repo-filters.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(includes, ",") != "foo:*,base:*" || strings.Join(excludes, ",") != "626147582d2a" {
+		t.Fatalf("read includes %v and excludes %v", includes, excludes)
+	}
+
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	var ids []string
+	for _, image := range selectImages(*im, includes, excludes) {
+		ids = append(ids, truncate(image.Id))
+	}
+	if strings.Join(ids, ",") != "c87be8e5e697,735f5db56261,4c1208b690c6" {
+		t.Fatalf("filter file selected %v", ids)
+	}
+
+	if _, _, err := readFilterFile("testdata/no-such-filters.txt"); err == nil {
+		t.Fatal("expected an error for a missing filter file")
+	}
+}
+
+func Test_ExecuteKeepsOptions(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "images.json")
+	ioutil.WriteFile(input, []byte(treeJSON), 0644)
+	command := ImagesCommand{Output: filepath.Join(dir, "tree.json"), FilterFile: "testdata/repo-filters.txt", Include: []string{"bar:*"}, TruncLength: 12}
+
+	originalIn, originalCommand := os.Stdin, imagesCommand
+	defer func() { os.Stdin, imagesCommand = originalIn, originalCommand }()
+
+	// options inferred or read from files must not pile up between runs
+	imagesCommand = command
+	for run := 1; run <= 2; run++ {
+		stdin, _ := os.Open(input)
+		os.Stdin = stdin
+		err := imagesCommand.Execute(nil)
+		stdin.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(imagesCommand, command) {
+			t.Fatalf("run %d changed the options to %+v", run, imagesCommand)
+		}
+	}
+}
+
+func Test_WithLabels(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["base:latest"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest"],"Labels":{"maintainer":"ops"}},{"Id":"4444444444444444","ParentId":"1111111111111111","RepoTags":["tool:latest"],"Labels":{}},{"Id":"5555555555555555","RepoTags":["alpine:3"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	var kept []string
+	for _, image := range withLabels(*im) {
+		kept = append(kept, image.Id[:4])
+	}
+	if strings.Join(kept, " ") != "1111 2222 3333" {
+		t.Fatalf("kept %v, expected the labelled image and its ancestors", kept)
+	}
+
+	im, _ = parseImagesJSON([]byte(treeJSON))
+	if kept := withLabels(*im); len(kept) != 0 {
+		t.Fatalf("kept %d images although none are labelled", len(kept))
+	}
+}
+
+func Test_SizeBudget(t *testing.T) {
+	sizes := map[string]int64{"512": 512, "1.5GB": 1500000000, "200MiB": 200 * 1024 * 1024, "10 kb": 10000, "3B": 3}
+	for size, expected := range sizes {
+		if parsed, err := parseSize(size); err != nil || parsed != expected {
+			t.Errorf("'%s' parsed as %d (%v), expected %d", size, parsed, err, expected)
+		}
+	}
+	for _, invalid := range []string{"", "big", "5XB", "-1MB"} {
+		if _, err := parseSize(invalid); err == nil {
+			t.Errorf("invalid size '%s' did not cause an error", invalid)
+		}
+	}
+
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	err := checkSizeBudget(*im, 700000000, "si", 12)
+	expected := "2 images are over the size budget of 700.0 MB:\n  base:latest (712.6 MB)\n  aaf8d4d1bcca (752.6 MB)"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("budget error was '%v', expected '%s'", err, expected)
+	}
+
+	if err := checkSizeBudget(*im, 800000000, "si", 12); err != nil {
+		t.Fatalf("images under budget caused an error: %s", err)
+	}
+
+	for _, zero := range []string{"0", "0MB", "0.0001B"} {
+		if _, err := executeImages(t, ImagesCommand{Tree: true, FailOver: zero, TruncLength: 12}, treeJSON); err == nil || !strings.Contains(err.Error(), "above zero") {
+			t.Errorf("budget '%s' was not rejected: %v", zero, err)
+		}
+	}
+}
+
+func Test_SplitRepoTag(t *testing.T) {
+	tests := []struct {
+		repotag, repo, tag string
+	}{
+		{"nginx:latest", "nginx", "latest"},
+		{"registry:5000/foo", "registry:5000/foo", ""},
+		{"registry:5000/foo:latest", "registry:5000/foo", "latest"},
+		{"registry:5000/team/foo:1.2", "registry:5000/team/foo", "1.2"},
+		{"foo", "foo", ""},
+	}
+
+	for _, test := range tests {
+		if repo, tag := splitRepoTag(test.repotag); repo != test.repo || tag != test.tag {
+			t.Errorf("'%s' split into '%s' and '%s', expected '%s' and '%s'", test.repotag, repo, tag, test.repo, test.tag)
+		}
+	}
+}
+
+func Test_FormatSize(t *testing.T) {
+	sizeTests := []struct {
+		raw      int64
+		format   string
+		expected string
+	}{
+		{999, "si", "999.0 B"},
+		{1000, "si", "1.0 KB"},
+		{1024, "si", "1.0 KB"},
+		{1000000, "si", "1.0 MB"},
+		{1000, "", "1.0 KB"},
+		{1000, "iec", "1000.0 B"},
+		{1023, "iec", "1023.0 B"},
+		{1024, "iec", "1.0 KiB"},
+		{1048576, "iec", "1.0 MiB"},
+		{1000, "raw", "1000"},
+		{1024, "raw", "1024"},
+		{1048576, "raw", "1048576"},
+	}
+
+	for _, sizeTest := range sizeTests {
+		result := formatSize(sizeTest.raw, sizeTest.format)
+		if result != sizeTest.expected {
+			t.Errorf("formatSize(%d, '%s') = '%s', expected '%s'", sizeTest.raw, sizeTest.format, result, sizeTest.expected)
+		}
+	}
+}
+
+func Test_Short(t *testing.T) {
+	shortJSON := `[ { "VirtualSize": 662553464, "Size": 0, "RepoTags": [ "foo:latest" ], "ParentId": "735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Id": "c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470", "Created": 1386142123 }, { "VirtualSize": 682553464, "Size": 0, "RepoTags": [ "foo:1.0" ], "ParentId": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Id": "626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Created": 1386142123 }, { "VirtualSize": 712553464, "Size": 0, "RepoTags": [ "foo:2.0" ], "ParentId": "626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Id": "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", "Created": 1386142123 }, { "VirtualSize": 752553464, "Size": 0, "RepoTags": [ "private.repo.com:5000:latest" ], "ParentId": "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", "Id": "aaf8d4d1bccab994574c5f626147582d2ae3735f5db5f2c87be8e5e697c08870", "Created": 1386142123 }, { "VirtualSize": 662553464, "Size": 0, "RepoTags": [ "<none>:<none>" ], "ParentId": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Id": "735f5db5626147582d2ae3f2c87be8e5e697c088574c5faaf8d4d1bccab99470", "Created": 1386142123 }, { "VirtualSize": 662553464, "Size": 662553464, "RepoTags": [ "<none>:<none>" ], "ParentId": "", "Id": "4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358", "Created": 1386114144 } ]`
+
+	shortTests := []ShortTest{
+		ShortTest{
+			json: shortJSON,
+			regexps: []string{
+				`(?m)foo: latest, 1.0, 2.0`,
+				`(?m)private.repo.com:5000: latest`,
+			},
+		},
+	}
+
+	for _, shortTest := range shortTests {
+		im, _ := parseImagesJSON([]byte(shortTest.json))
+		result := jsonToShort(im, ShortOptions{})
+
+		for _, regexp := range compileRegexps(t, shortTest.regexps) {
+			if !regexp.MatchString(result) {
+				t.Fatalf("images short content '%s' did not match regexp '%s'", result, regexp)
+			}
+		}
+	}
+}
+
+func Test_ShortWithDepth(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	result := jsonToShort(im, ShortOptions{WithDepth: true})
+
+	regexps := []string{
+		`(?m)^foo: latest \(depth 2\)$`,
+		`(?m)^base: latest \(depth 2\)$`,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("images short content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["app:base"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest"]},{"Id":"4444444444444444","ParentId":"1111111111111111","RepoTags":["db:latest"]}]`
+	im, _ = parseImagesJSON([]byte(json))
+	result = jsonToShort(im, ShortOptions{WithDepth: true})
+
+	regexps = []string{
+		`(?m)^app: latest, base \(depth 2\)$`,
+		`(?m)^db: latest \(depth 1\)$`,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("images short content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+}
+
+func Test_ShortSortRepos(t *testing.T) {
+	json := `[{"Id":"1111111111111111","VirtualSize":300,"RepoTags":["web:1","web:2"]},{"Id":"2222222222222222","VirtualSize":100,"RepoTags":["api:latest"]},{"Id":"3333333333333333","VirtualSize":500,"RepoTags":["db:9","db:10","db:11"]},{"Id":"4444444444444444","VirtualSize":50,"RepoTags":["web:3"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	tests := []struct {
+		sortBy   string
+		reverse  bool
+		expected string
+	}{
+		{"", false, "api: latest\ndb: 9, 10, 11\nweb: 1, 2, 3\n"},
+		{"name", true, "web: 1, 2, 3\ndb: 9, 10, 11\napi: latest\n"},
+		{"tags", false, "api: latest\ndb: 9, 10, 11\nweb: 1, 2, 3\n"},
+		{"tags", true, "web: 1, 2, 3\ndb: 9, 10, 11\napi: latest\n"},
+		{"size", false, "api: latest\nweb: 1, 2, 3\ndb: 9, 10, 11\n"},
+		{"size", true, "db: 9, 10, 11\nweb: 1, 2, 3\napi: latest\n"},
+	}
+	for _, test := range tests {
+		result := jsonToShort(im, ShortOptions{SortBy: test.sortBy, Reverse: test.reverse})
+		if result != test.expected {
+			t.Errorf("sorting by '%s' (reverse %v) gave\n%s\nexpected\n%s", test.sortBy, test.reverse, result, test.expected)
+		}
+	}
+
+	// lineage puts the repositories with the most built on them first
+	json = `[{"Id":"1111111111111111","Size":100,"RepoTags":["debian:12"]},{"Id":"2222222222222222","ParentId":"1111111111111111","Size":300,"RepoTags":["app:latest"]},{"Id":"3333333333333333","Size":10,"RepoTags":["alpine:3"]},{"Id":"4444444444444444","ParentId":"3333333333333333","Size":50,"RepoTags":["tool:1"]}]`
+	im, _ = parseImagesJSON([]byte(json))
+	if result := jsonToShort(im, ShortOptions{SortBy: "lineage"}); result != "debian: 12\nalpine: 3\napp: latest\ntool: 1\n" {
+		t.Errorf("sorting by lineage gave\n%s", result)
+	}
+	if result := jsonToShort(im, ShortOptions{SortBy: "lineage", Reverse: true}); result != "tool: 1\napp: latest\nalpine: 3\ndebian: 12\n" {
+		t.Errorf("sorting by lineage in reverse gave\n%s", result)
+	}
+}
+
+func Test_ShortAlign(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["db:9"]},{"Id":"2222222222222222","RepoTags":["registry:5000/team/web:1","registry:5000/team/web:2"]},{"Id":"3333333333333333","RepoTags":["api:latest"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := jsonToShort(im, ShortOptions{Align: true})
+	expected := `api:                    latest
+db:                     9
+registry:5000/team/web: 1, 2
+`
+	if result != expected {
+		t.Fatalf("aligned short was\n%s\nexpected\n%s", result, expected)
+	}
+
+	result = jsonToShort(im, ShortOptions{Align: true, MaxAlign: 4})
+	expected = `api:  latest
+db:   9
+registry:5000/team/web: 1, 2
+`
+	if result != expected {
+		t.Fatalf("capped aligned short was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_ShortFlatten(t *testing.T) {
+	json := `[{"Id":"2222222222222222","RepoTags":["web:1","registry:5000/web:1","api:latest"]},{"Id":"1111111111111111","RepoTags":["db:9"]},{"Id":"3333333333333333","RepoTags":["<none>:<none>"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := jsonToShort(im, ShortOptions{Flatten: true})
+	expected := `111111111111: db:9
+222222222222: web:1, registry:5000/web:1, api:latest
+`
+	if result != expected {
+		t.Fatalf("flattened short was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_ShortWithAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	json := fmt.Sprintf(`[{"Id":"1111111111111111","RepoTags":["nginx:1.21"],"Created":%d},{"Id":"2222222222222222","RepoTags":["nginx:latest","nginx:1.25"],"Created":%d},{"Id":"3333333333333333","RepoTags":["nginx:1.0"],"Created":%d},{"Id":"4444444444444444","RepoTags":["redis:7"],"Created":%d},{"Id":"5555555555555555","RepoTags":["old:1"]}]`,
+		now.Add(-30*24*time.Hour).Unix(), now.Add(-50*time.Hour).Unix(), now.Add(-800*24*time.Hour).Unix(), now.Add(-90*time.Minute).Unix())
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := jsonToShort(im, ShortOptions{WithAge: true, Now: now})
+	expected := `nginx: latest (2d), 1.0 (2y), 1.21 (30d), 1.25 (2d)
+old: 1
+redis: 7 (1h)
+`
+	if result != expected {
+		t.Fatalf("short with ages was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_ShortOneline(t *testing.T) {
+	json := `[{"Id":"2222222222222222","RepoTags":["web:1","registry:5000/web:1","api:latest"]},{"Id":"1111111111111111","RepoTags":["db:9","web:1"]},{"Id":"3333333333333333","RepoTags":["<none>:<none>"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := jsonToShort(im, ShortOptions{Oneline: true})
+	expected := `api:latest
+db:9
+registry:5000/web:1
+web:1
+`
+	if result != expected {
+		t.Fatalf("one-line short was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_ShortExpand(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	result := jsonToShort(im, ShortOptions{Expand: true, Tree: TreeOptions{NoSizeLabel: true}})
+
+	regexps := []string{
+		`(?m)^base: latest\n  └─4c1208b690c6 [^\n]*\n    └─574c5faaf8d4 [^\n]* Tags: base:latest\n`,
+		`(?m)^foo: latest\n  └─4c1208b690c6 [^\n]*\n    └─c87be8e5e697 [^\n]* Tags: foo:latest\n`,
+	}
+	for _, regexp := range compileRegexps(t, regexps) {
+		if !regexp.MatchString(result) {
+			t.Fatalf("images short content '%s' did not match regexp '%s'", result, regexp)
+		}
+	}
+	if strings.Count(result, "\n") != 6 {
+		t.Fatalf("expanded lineages pulled in unrelated images: '%s'", result)
+	}
+}
+
+func Test_IndentStyles(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["base:latest"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["app:latest"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["web:latest"]},{"Id":"4444444444444444","ParentId":"1111111111111111","RepoTags":["db:latest"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	expected := map[string]string{
+		"unicode": "└─111111111111 Tags: base:latest\n  ├─222222222222 Tags: app:latest\n  │ └─333333333333 Tags: web:latest\n  └─444444444444 Tags: db:latest\n",
+		"ascii":   "`-111111111111 Tags: base:latest\n  |-222222222222 Tags: app:latest\n  | `-333333333333 Tags: web:latest\n  `-444444444444 Tags: db:latest\n",
+		"spaces":  "  111111111111 Tags: base:latest\n    222222222222 Tags: app:latest\n      333333333333 Tags: web:latest\n    444444444444 Tags: db:latest\n",
+		"dots":    "··111111111111 Tags: base:latest\n  ··222222222222 Tags: app:latest\n  · ··333333333333 Tags: web:latest\n  ··444444444444 Tags: db:latest\n",
+	}
+	for style, tree := range expected {
+		result := jsonToTree(collectRoots(im), collectChildren(im), TreeOptions{IndentStyle: style})
+		result = regexp.MustCompile(` Virtual Size: [^ ]+ B`).ReplaceAllString(result, "")
+		if result != tree {
+			t.Errorf("%s style tree was\n%s\nexpected\n%s", style, result, tree)
+		}
+	}
+}
+
+func Test_MaxWidth(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["registry.example.com/team/base:latest","registry.example.com/team/base:2024-01","registry.example.com/team/base:2024-02"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["registry.example.com/team/app:latest","registry.example.com/team/app:v1"]},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["tool:1"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := jsonToTree(collectRoots(im), collectChildren(im), TreeOptions{NoSizeLabel: true, MaxWidth: 80})
+	expected := `└─111111111111 0.0 B Tags: registry.example.com/team/base:latest,
+                           registry.example.com/team/base:2024-01,
+                           registry.example.com/team/base:2024-02
+  ├─222222222222 0.0 B Tags: registry.example.com/team/app:latest,
+  │                          registry.example.com/team/app:v1
+  └─333333333333 0.0 B Tags: tool:1
+`
+	if result != expected {
+		t.Fatalf("wrapped tree was\n%s\nexpected\n%s", result, expected)
+	}
+
+	if result := jsonToTree(collectRoots(im), collectChildren(im), TreeOptions{MaxWidth: -1}); strings.Count(result, "\n") != 3 {
+		t.Fatalf("tree was wrapped with a negative width: '%s'", result)
+	}
+}
+
+func Test_OnlyRoots(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["ubuntu:22.04","ubuntu:latest"],"VirtualSize":70000},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["app:latest"],"VirtualSize":90000},{"Id":"3333333333333333","ParentId":"","RepoTags":["alpine:3"],"VirtualSize":5000},{"Id":"4444444444444444","ParentId":"","RepoTags":["<none>:<none>"],"VirtualSize":100},{"Id":"5555555555555555","ParentId":"gone","RepoTags":["orphan:1"],"VirtualSize":800}]`
+	im, _ := parseImagesJSON([]byte(json))
+	images := promoteOrphans(*im, false)
+
+	result := listRoots(images, TreeOptions{NoSizeLabel: true}, "name", false)
+	expected := `444444444444 100.0 B
+333333333333 5.0 KB Tags: alpine:3
+555555555555 800.0 B Tags: orphan:1
+111111111111 70.0 KB Tags: ubuntu:22.04, ubuntu:latest
+`
+	if result != expected {
+		t.Fatalf("roots were\n%s\nexpected\n%s", result, expected)
+	}
+
+	result = listRoots(images, TreeOptions{NoTruncate: true}, "size", true)
+	expected = `1111111111111111 Virtual Size: 70.0 KB Tags: ubuntu:22.04, ubuntu:latest
+3333333333333333 Virtual Size: 5.0 KB Tags: alpine:3
+5555555555555555 Virtual Size: 800.0 B Tags: orphan:1
+4444444444444444 Virtual Size: 100.0 B
+`
+	if result != expected {
+		t.Fatalf("roots by size were\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_OnlyRootsByLineage(t *testing.T) {
+	// small roots with big descendants, and a big root with small ones
+	json := `[{"Id":"1111111111111111","RepoTags":["alpine:3"],"Size":5,"VirtualSize":5},{"Id":"1222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Size":400,"VirtualSize":405},{"Id":"1333333333333333","ParentId":"1222222222222222","RepoTags":["app:1"],"Size":300,"VirtualSize":705},{"Id":"1444444444444444","ParentId":"1111111111111111","RepoTags":["tool:1"],"Size":100,"VirtualSize":105},{"Id":"2111111111111111","RepoTags":["ubuntu:22.04"],"Size":700,"VirtualSize":700},{"Id":"2222222222222222","ParentId":"2111111111111111","RepoTags":["web:1"],"Size":600,"VirtualSize":1300},{"Id":"3111111111111111","RepoTags":["debian:12"],"Size":900,"VirtualSize":900}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	sizes := descendantSizes(*im)
+	if sizes["1111111111111111"] != 800 || sizes["1222222222222222"] != 300 || sizes["2111111111111111"] != 600 || sizes["3111111111111111"] != 0 {
+		t.Fatalf("unexpected descendant sizes %v", sizes)
+	}
+
+	result := listRoots(*im, TreeOptions{NoSizeLabel: true}, "lineage", false)
+	expected := `111111111111 5.0 B Tags: alpine:3
+211111111111 700.0 B Tags: ubuntu:22.04
+311111111111 900.0 B Tags: debian:12
+`
+	if result != expected {
+		t.Fatalf("roots by lineage were\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_SortStability(t *testing.T) {
+	images := []Image{
+		{Id: "cccccccccccccccc", RepoTags: []string{"app:latest"}, VirtualSize: 500, Created: 100},
+		{Id: "aaaaaaaaaaaaaaaa", RepoTags: []string{"app:latest"}, VirtualSize: 500, Created: 100},
+		{Id: "bbbbbbbbbbbbbbbb", RepoTags: []string{"app:latest"}, VirtualSize: 500, Created: 100},
+	}
+
+	for rotation := 0; rotation < len(images); rotation++ {
+		rotated := append(append([]Image{}, images[rotation:]...), images[:rotation]...)
+
+		result := listRoots(rotated, TreeOptions{NoSizeLabel: true}, "size", false)
+		expected := `aaaaaaaaaaaa 500.0 B Tags: app:latest
+bbbbbbbbbbbb 500.0 B Tags: app:latest
+cccccccccccc 500.0 B Tags: app:latest
+`
+		if result != expected {
+			t.Fatalf("equal-sized roots were\n%s\nexpected\n%s", result, expected)
+		}
+
+		inferParents(rotated)
+		parents := make(map[string]string)
+		for _, image := range rotated {
+			parents[image.Id] = image.ParentId
+		}
+		if parents["bbbbbbbbbbbbbbbb"] != "aaaaaaaaaaaaaaaa" || parents["cccccccccccccccc"] != "bbbbbbbbbbbbbbbb" {
+			t.Fatalf("images created together were chained as %v", parents)
+		}
+	}
+}
+
+func Test_Reclaimable(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["base:latest"],"Size":100},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Size":200},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Size":300,"Containers":1},{"Id":"4444444444444444","ParentId":"","RepoTags":["<none>:<none>"],"Size":400},{"Id":"5555555555555555","ParentId":"4444444444444444","RepoTags":["app:latest"],"Size":500},{"Id":"6666666666666666","ParentId":"","RepoTags":["<none>:<none>"],"Size":600}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	reclaimable := reclaimableImages(*im)
+	opts := TreeOptions{NoSizeLabel: true, Incremental: true, ShowUsage: true, Reclaimable: make(map[string]bool)}
+	for _, image := range reclaimable {
+		opts.Reclaimable[image.Id] = true
+	}
+
+	roots, byParent := prepareTree(im, nil, true)
+	result := jsonToTree(roots, byParent, opts) + reclaimableSummary(reclaimable, "si")
+	expected := `├─111111111111 100.0 B Tags: base:latest
+│ ├─222222222222 200.0 B [reclaimable]
+│ └─333333333333 300.0 B Containers: 1
+├─444444444444 400.0 B
+│ └─555555555555 500.0 B Tags: app:latest
+└─666666666666 600.0 B [reclaimable]
+Reclaimable: 800.0 B in 2 images
+`
+	if result != expected {
+		t.Fatalf("reclaimable tree was\n%s\nexpected\n%s", result, expected)
+	}
+
+	// without --all-layers the reclaimable images are still shown and
+	// the footer totals what is shown
+	written, err := executeImages(t, ImagesCommand{Tree: true, Reclaimable: true, Incremental: true, NoSizeLabel: true, SizeFormat: "si", TruncLength: 12}, json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `├─111111111111 100.0 B Tags: base:latest
+│ ├─222222222222 200.0 B [reclaimable]
+│ └─333333333333 300.0 B
+├─444444444444 400.0 B
+│ └─555555555555 500.0 B Tags: app:latest
+└─666666666666 600.0 B [reclaimable]
+Reclaimable: 800.0 B in 2 images
+`
+	if written != expected {
+		t.Fatalf("reclaimable tree by default was\n%s\nexpected\n%s", written, expected)
+	}
+}
+
+func Test_PrunePlan(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["base:latest"],"Size":100},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Size":200},{"Id":"2222222222222223","ParentId":"2222222222222222","RepoTags":["<none>:<none>"],"Size":250},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Size":300,"Containers":1},{"Id":"4444444444444444","ParentId":"","RepoTags":["<none>:<none>"],"Size":400},{"Id":"5555555555555555","ParentId":"4444444444444444","RepoTags":["app:latest"],"Size":500},{"Id":"6666666666666666","ParentId":"","RepoTags":["<none>:<none>"],"Size":600}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := prunePlan(*im, "si")
+	expected := `#!/bin/sh
+docker rmi 2222222222222223
+docker rmi 2222222222222222
+docker rmi 6666666666666666
+# total reclaimable: 1.1 KB
+`
+	if result != expected {
+		t.Fatalf("prune plan was\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_RepoTotals(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["<none>:<none>"],"VirtualSize":100000000},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["app:latest","app:1.0","registry.local:5000/app:1.0"],"VirtualSize":300000000},{"Id":"3333333333333333","ParentId":"1111111111111111","RepoTags":["app:0.9"],"VirtualSize":200000000},{"Id":"4444444444444444","ParentId":"1111111111111111","RepoTags":["db:latest"],"VirtualSize":400000000}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := repoTotals(*im, "si")
+	expected := `REPOSITORY               IMAGES  SIZE
+app                      2       500.0 MB
+db                       1       400.0 MB
+registry.local:5000/app  1       300.0 MB
+`
+	if result != expected {
+		t.Fatalf("repo totals were '%s', expected '%s'", result, expected)
 	}
 }
 
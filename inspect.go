@@ -0,0 +1,117 @@
+package main
+
+import (
+	"github.com/fsouza/go-dockerclient"
+
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type InspectCommand struct {
+	History    bool   `long:"history" description:"List the commands that created each layer of the image."`
+	SizeFormat string `long:"format-size" choice:"si" choice:"iec" choice:"raw" default:"si" description:"Display sizes in SI (1000-based) or IEC (1024-based) units, or as raw bytes."`
+}
+
+var inspectCommand InspectCommand
+
+func (x *InspectCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Please name one image, e.g. inspect <image>")
+	}
+
+	var client *docker.Client
+
+	images, err := stdinImages()
+	if err != nil {
+		return err
+	}
+	if images == nil {
+		client, err = connect()
+		if err != nil {
+			return err
+		}
+
+		ims, err := daemonImages(client, true, "inspect")
+		if err != nil {
+			return err
+		}
+		images = &ims
+	}
+
+	image, err := findStartImage(args[0], images)
+	if err != nil {
+		return err
+	}
+
+	if inspectCommand.History && client != nil {
+		selected := []Image{*image}
+		if err := fetchHistories(timeoutHistoryClient{client, globalOptions.Timeout}, selected, 1, defaultTruncLength, os.Stderr, true); err != nil {
+			return err
+		}
+		*image = selected[0]
+	}
+
+	fmt.Print(inspectReport(*image, *images, inspectCommand.History, inspectCommand.SizeFormat))
+
+	return nil
+}
+
+// inspectReport describes everything known about one image.
+func inspectReport(image Image, images []Image, history bool, sizeFormat string) string {
+	none := func(values []string) string {
+		if len(values) == 0 {
+			return "<none>"
+		}
+		return strings.Join(values, ", ")
+	}
+
+	var tags []string
+	if isTagged(image) {
+		tags = image.RepoTags
+	}
+	parent := image.ParentId
+	if len(parent) == 0 {
+		parent = "<none>"
+	}
+	created := "<unknown>"
+	if image.Created > 0 {
+		created = time.Unix(image.Created, 0).UTC().Format("2006-01-02 15:04:05 MST")
+	}
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "Id:\t%s\n", image.Id)
+	fmt.Fprintf(writer, "Tags:\t%s\n", none(tags))
+	fmt.Fprintf(writer, "Digests:\t%s\n", none(image.RepoDigests))
+	fmt.Fprintf(writer, "Created:\t%s\n", created)
+	fmt.Fprintf(writer, "Virtual Size:\t%s\n", formatSize(image.VirtualSize, sizeFormat))
+	fmt.Fprintf(writer, "Size:\t%s\n", formatSize(image.Size, sizeFormat))
+	fmt.Fprintf(writer, "Parent:\t%s\n", parent)
+	fmt.Fprintf(writer, "Children:\t%d\n", len(collectChildren(&images)[image.Id]))
+	writer.Flush()
+
+	if history {
+		buffer.WriteString("History:\n")
+		if len(image.History) == 0 {
+			buffer.WriteString("  <not available>\n")
+		}
+		writer = tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+		for _, layer := range image.History {
+			fmt.Fprintf(writer, "  %s\t%s\n", formatSize(layer.Size, sizeFormat), createdBy(layer, true))
+		}
+		writer.Flush()
+	}
+
+	return buffer.String()
+}
+
+func init() {
+	parser.AddCommand("inspect",
+		"Show everything about one image.",
+		"",
+		&inspectCommand)
+}
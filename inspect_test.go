@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_InspectReport(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	image, err := findStartImage("base", im)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image.RepoDigests = []string{"base@sha256:0123"}
+	image.History = []Layer{
+		{Id: image.Id, CreatedBy: "/bin/sh -c #(nop) CMD [\"sh\"]", Size: 0},
+		{Id: "<missing>", CreatedBy: "/bin/sh -c apt-get install -y curl", Size: 30000000},
+	}
+
+	result := inspectReport(*image, *im, true, "si")
+	expected := `Id:            574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870
+Tags:          base:latest
+Digests:       base@sha256:0123
+Created:       2013-12-04 07:28:43 UTC
+Virtual Size:  712.6 MB
+Size:          30.0 MB
+Parent:        626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470
+Children:      1
+History:
+  0.0 B    CMD ["sh"]
+  30.0 MB  apt-get install -y curl
+`
+	if result != expected {
+		t.Fatalf("report was\n%s\nexpected\n%s", result, expected)
+	}
+
+	root, _ := findStartImage("4c1208b690c6", im)
+	result = inspectReport(*root, *im, false, "si")
+	expected = `Id:            4c1208b690c68af3476b437e7bc2bcc460f062bda2094d2d8f21a7e70368d358
+Tags:          <none>
+Digests:       <none>
+Created:       2013-12-03 23:42:24 UTC
+Virtual Size:  662.6 MB
+Size:          662.6 MB
+Parent:        <none>
+Children:      2
+`
+	if result != expected {
+		t.Fatalf("report was\n%s\nexpected\n%s", result, expected)
+	}
+}
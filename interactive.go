@@ -0,0 +1,252 @@
+package main
+
+import (
+	"github.com/atotto/clipboard"
+	"github.com/nsf/termbox-go"
+
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type treeNode struct {
+	image    Image
+	parent   *treeNode
+	children []*treeNode
+	depth    int
+	expanded bool
+}
+
+type treeBrowser struct {
+	roots    []*treeNode
+	visible  []*treeNode
+	selected int
+}
+
+func newTreeBrowser(roots []Image, byParent map[string][]Image) *treeBrowser {
+	browser := &treeBrowser{
+		roots: buildTreeNodes(roots, byParent, nil, 0),
+	}
+	browser.refresh()
+
+	return browser
+}
+
+func buildTreeNodes(images []Image, byParent map[string][]Image, parent *treeNode, depth int) []*treeNode {
+	var nodes []*treeNode
+	for _, image := range images {
+		node := &treeNode{image: image, parent: parent, depth: depth}
+		if subimages, exists := byParent[image.Id]; exists {
+			node.children = buildTreeNodes(subimages, byParent, node, depth+1)
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+// refresh rebuilds the list of visible rows, keeping the selection on
+// the same node when it is still visible.
+func (b *treeBrowser) refresh() {
+	current := b.Selected()
+
+	b.visible = b.visible[:0]
+	var walk func(nodes []*treeNode)
+	walk = func(nodes []*treeNode) {
+		for _, node := range nodes {
+			b.visible = append(b.visible, node)
+			if node.expanded {
+				walk(node.children)
+			}
+		}
+	}
+	walk(b.roots)
+
+	b.selected = 0
+	for index, node := range b.visible {
+		if node == current {
+			b.selected = index
+		}
+	}
+}
+
+func (b *treeBrowser) Selected() *treeNode {
+	if b.selected < 0 || b.selected >= len(b.visible) {
+		return nil
+	}
+	return b.visible[b.selected]
+}
+
+func (b *treeBrowser) Up() {
+	if b.selected > 0 {
+		b.selected--
+	}
+}
+
+func (b *treeBrowser) Down() {
+	if b.selected < len(b.visible)-1 {
+		b.selected++
+	}
+}
+
+// Expand opens the selected node, or moves to its first child if it is
+// already open.
+func (b *treeBrowser) Expand() {
+	node := b.Selected()
+	if node == nil || len(node.children) == 0 {
+		return
+	}
+	if node.expanded {
+		b.Down()
+		return
+	}
+	node.expanded = true
+	b.refresh()
+}
+
+// Collapse closes the selected node, or moves to its parent if it is
+// already closed or has no children.
+func (b *treeBrowser) Collapse() {
+	node := b.Selected()
+	if node == nil {
+		return
+	}
+	if node.expanded {
+		node.expanded = false
+	} else if node.parent != nil {
+		b.selected = b.indexOf(node.parent)
+	}
+	b.refresh()
+}
+
+func (b *treeBrowser) Toggle() {
+	node := b.Selected()
+	if node == nil || len(node.children) == 0 {
+		return
+	}
+	node.expanded = !node.expanded
+	b.refresh()
+}
+
+func (b *treeBrowser) indexOf(target *treeNode) int {
+	for index, node := range b.visible {
+		if node == target {
+			return index
+		}
+	}
+	return 0
+}
+
+func browseTree(roots []Image, byParent map[string][]Image, opts TreeOptions) error {
+	stat, err := os.Stdout.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading stdout stat: %s", err)
+	}
+	if (stat.Mode() & os.ModeCharDevice) == 0 {
+		return fmt.Errorf("--interactive requires a terminal on stdout")
+	}
+
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+	defer termbox.Close()
+
+	// each node is one row, clipped to the screen rather than wrapped
+	opts.MaxWidth = 0
+
+	browser := newTreeBrowser(roots, byParent)
+	status := "arrows: move/expand/collapse  enter: toggle  c: copy id  q: quit"
+	offset := 0
+
+	for {
+		offset = drawTreeBrowser(browser, opts, status, offset)
+
+		event := termbox.PollEvent()
+		if event.Type == termbox.EventError {
+			return event.Err
+		}
+		if event.Type != termbox.EventKey {
+			continue
+		}
+
+		switch {
+		case event.Key == termbox.KeyArrowUp:
+			browser.Up()
+		case event.Key == termbox.KeyArrowDown:
+			browser.Down()
+		case event.Key == termbox.KeyArrowRight:
+			browser.Expand()
+		case event.Key == termbox.KeyArrowLeft:
+			browser.Collapse()
+		case event.Key == termbox.KeyEnter || event.Key == termbox.KeySpace:
+			browser.Toggle()
+		case event.Ch == 'c':
+			if node := browser.Selected(); node != nil {
+				if err := clipboard.WriteAll(node.image.Id); err != nil {
+					status = fmt.Sprintf("unable to copy: %s", err)
+				} else {
+					status = fmt.Sprintf("copied %s", node.image.Id)
+				}
+			}
+		case event.Ch == 'q' || event.Key == termbox.KeyEsc || event.Key == termbox.KeyCtrlC:
+			return nil
+		}
+	}
+}
+
+func drawTreeBrowser(browser *treeBrowser, opts TreeOptions, status string, offset int) int {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+
+	// keep the selection inside the window, leaving a row for the status
+	rows := height - 1
+	if browser.selected < offset {
+		offset = browser.selected
+	} else if rows > 0 && browser.selected >= offset+rows {
+		offset = browser.selected - rows + 1
+	}
+
+	for row := 0; row < rows && offset+row < len(browser.visible); row++ {
+		node := browser.visible[offset+row]
+
+		fg, bg := termbox.ColorDefault, termbox.ColorDefault
+		if offset+row == browser.selected {
+			fg, bg = termbox.ColorDefault|termbox.AttrReverse, termbox.ColorDefault|termbox.AttrReverse
+		}
+		drawString(0, row, width, browserLine(node, opts), fg, bg)
+	}
+	drawString(0, height-1, width, status, termbox.ColorDefault|termbox.AttrBold, termbox.ColorDefault)
+
+	termbox.Flush()
+
+	return offset
+}
+
+// browserLine renders a node as one row of the browser.  Termbox draws each
+// character as a cell, so the line is kept free of escape sequences.
+func browserLine(node *treeNode, opts TreeOptions) string {
+	marker := "  "
+	if len(node.children) > 0 {
+		if node.expanded {
+			marker = "▾ "
+		} else {
+			marker = "▸ "
+		}
+	}
+
+	opts.Plain = true
+	var line bytes.Buffer
+	PrintTreeNode(&line, node.image, opts, strings.Repeat("  ", node.depth)+marker)
+	return strings.TrimRight(line.String(), "\n")
+}
+
+func drawString(x, y, width int, text string, fg, bg termbox.Attribute) {
+	for _, ch := range text {
+		if x >= width {
+			return
+		}
+		termbox.SetCell(x, y, ch, fg, bg)
+		x++
+	}
+}
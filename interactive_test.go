@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func browserFixture(t *testing.T) *treeBrowser {
+	// root
+	// ├─a
+	// │ └─a1
+	// └─b
+	json := `[{"Id":"root000000000000","ParentId":"","RepoTags":["<none>:<none>"]},{"Id":"a00000000000000","ParentId":"root000000000000","RepoTags":["<none>:<none>"]},{"Id":"a10000000000000","ParentId":"a00000000000000","RepoTags":["a:latest"]},{"Id":"b00000000000000","ParentId":"root000000000000","RepoTags":["b:latest"]}]`
+
+	im, err := parseImagesJSON([]byte(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTreeBrowser(collectRoots(im), collectChildren(im))
+}
+
+func assertSelected(t *testing.T, browser *treeBrowser, id string) {
+	node := browser.Selected()
+	if node == nil {
+		t.Fatalf("expected %s to be selected, got nothing", id)
+	}
+	if node.image.Id != id {
+		t.Fatalf("expected %s to be selected, got %s", id, node.image.Id)
+	}
+}
+
+func assertVisible(t *testing.T, browser *treeBrowser, count int) {
+	if len(browser.visible) != count {
+		t.Fatalf("expected %d visible rows, got %d", count, len(browser.visible))
+	}
+}
+
+func Test_BrowserStartsCollapsed(t *testing.T) {
+	browser := browserFixture(t)
+
+	assertVisible(t, browser, 1)
+	assertSelected(t, browser, "root000000000000")
+}
+
+func Test_BrowserExpandCollapse(t *testing.T) {
+	browser := browserFixture(t)
+
+	browser.Expand()
+	assertVisible(t, browser, 3)
+	assertSelected(t, browser, "root000000000000")
+
+	// expanding an open node moves into its first child
+	browser.Expand()
+	assertSelected(t, browser, "a00000000000000")
+
+	browser.Expand()
+	assertVisible(t, browser, 4)
+
+	// collapsing a leaf only moves to its parent, leaving the siblings shown
+	browser.Down()
+	assertSelected(t, browser, "a10000000000000")
+	browser.Collapse()
+	assertSelected(t, browser, "a00000000000000")
+	assertVisible(t, browser, 4)
+
+	browser.Collapse()
+	assertSelected(t, browser, "a00000000000000")
+	assertVisible(t, browser, 3)
+
+	browser.Collapse()
+	assertSelected(t, browser, "root000000000000")
+	assertVisible(t, browser, 3)
+
+	browser.Collapse()
+	assertVisible(t, browser, 1)
+}
+
+func Test_BrowserSelectionBounds(t *testing.T) {
+	browser := browserFixture(t)
+
+	browser.Up()
+	assertSelected(t, browser, "root000000000000")
+
+	browser.Toggle()
+	browser.Down()
+	browser.Down()
+	browser.Down()
+	assertSelected(t, browser, "b00000000000000")
+
+	// leaves can't be toggled
+	browser.Toggle()
+	assertVisible(t, browser, 3)
+}
+
+func Test_BrowserLinePlain(t *testing.T) {
+	browser := browserFixture(t)
+	browser.Expand()
+
+	now := time.Now()
+	browser.visible[0].image.Created = now.Add(-48 * time.Hour).Unix()
+	browser.visible[1].image.Created = now.Unix()
+
+	opts := TreeOptions{NoSizeLabel: true, SizeFormat: "si", RecentSince: now.Add(-time.Hour)}
+	expected := []string{"▾ root00000000 0.0 B", "  ▸ a00000000000 0.0 B", "    b00000000000 0.0 B Tags: b:latest"}
+	for index, node := range browser.visible {
+		if line := browserLine(node, opts); line != expected[index] {
+			t.Errorf("row %d rendered as %q, expected %q", index, line, expected[index])
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// jsonStyle adjusts the JSON dockviz writes for tools expecting other
+// conventions.  The zero value leaves it as encoded.
+type jsonStyle struct {
+	// snake_case keys instead of camelCase
+	SnakeCase bool
+
+	// drop every null, zero, false or empty value
+	OmitEmpty bool
+}
+
+// apply rewrites encoded JSON in the style, keeping the order of the keys.
+// The result is compact.
+func (style jsonStyle) apply(encoded []byte) ([]byte, error) {
+	if !style.SnakeCase && !style.OmitEmpty {
+		return encoded, nil
+	}
+
+	trimmed := bytes.TrimSpace(encoded)
+	var buffer bytes.Buffer
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.Token()
+		buffer.WriteString("{")
+		first := true
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			if value, err = style.apply(value); err != nil {
+				return nil, err
+			}
+			if style.OmitEmpty && emptyJSON(value) {
+				continue
+			}
+
+			key := token.(string)
+			if style.SnakeCase {
+				key = snakeCase(key)
+			}
+			if !first {
+				buffer.WriteString(",")
+			}
+			first = false
+			encodedKey, _ := json.Marshal(key)
+			buffer.Write(encodedKey)
+			buffer.WriteString(":")
+			buffer.Write(value)
+		}
+		buffer.WriteString("}")
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var values []json.RawMessage
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return nil, err
+		}
+		buffer.WriteString("[")
+		for index, value := range values {
+			value, err := style.apply(value)
+			if err != nil {
+				return nil, err
+			}
+			if index > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.Write(value)
+		}
+		buffer.WriteString("]")
+	default:
+		return trimmed, nil
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func emptyJSON(value json.RawMessage) bool {
+	switch string(value) {
+	case "null", `""`, "0", "false", "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// snakeCase turns a camelCase key like parentId into parent_id.
+func snakeCase(key string) string {
+	var snake strings.Builder
+	for index, r := range key {
+		if unicode.IsUpper(r) {
+			if index > 0 {
+				snake.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		snake.WriteRune(r)
+	}
+	return snake.String()
+}
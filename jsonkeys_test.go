@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_JSONKeys(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(`[{"Id":"1111111111111111","RepoTags":["base:latest"],"VirtualSize":100},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"VirtualSize":0}]`))
+	roots, byParent := prepareTree(im, nil, true)
+
+	styles := []struct {
+		style    jsonStyle
+		expected string
+	}{
+		{jsonStyle{}, `{"id":"1111111111111111","depth":0,"size":100,"tags":["base:latest"]}
+{"id":"2222222222222222","parentId":"1111111111111111","depth":1,"size":0}
+`},
+		{jsonStyle{SnakeCase: true}, `{"id":"1111111111111111","depth":0,"size":100,"tags":["base:latest"]}
+{"id":"2222222222222222","parent_id":"1111111111111111","depth":1,"size":0}
+`},
+		{jsonStyle{SnakeCase: true, OmitEmpty: true}, `{"id":"1111111111111111","size":100,"tags":["base:latest"]}
+{"id":"2222222222222222","parent_id":"1111111111111111","depth":1}
+`},
+	}
+	for _, test := range styles {
+		var buffer bytes.Buffer
+		if err := writeNDJSON(&buffer, roots, byParent, TreeOptions{JSONStyle: test.style}); err != nil {
+			t.Fatal(err)
+		}
+		if buffer.String() != test.expected {
+			t.Errorf("style %+v gave\n%s\nexpected\n%s", test.style, buffer.String(), test.expected)
+		}
+	}
+
+	stats, _ := statsJSON(*im, jsonStyle{SnakeCase: true})
+	if !bytes.Contains([]byte(stats), []byte(`"total_virtual_size": 100`)) || !bytes.Contains([]byte(stats), []byte(`"largest_image": {`)) {
+		t.Fatalf("stats keys were not snake_case in %s", stats)
+	}
+}
+
+func Test_SnakeCase(t *testing.T) {
+	for camel, snake := range map[string]string{"id": "id", "parentId": "parent_id", "totalVirtualSize": "total_virtual_size"} {
+		if result := snakeCase(camel); result != snake {
+			t.Errorf("'%s' became '%s', expected '%s'", camel, result, snake)
+		}
+	}
+}
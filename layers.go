@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type sharedLayer struct {
+	layer  Layer
+	images []string
+}
+
+// layerKey identifies a history layer across images: by its id when the
+// daemon reports one, and otherwise by what created it.
+func layerKey(layer Layer) string {
+	if len(layer.Id) > 0 && layer.Id != "<missing>" {
+		return layer.Id
+	}
+	return fmt.Sprintf("%d %d %s", layer.Created, layer.Size, layer.CreatedBy)
+}
+
+// duplicateLayers finds the non-empty layers that appear in the histories of
+// tagged images from more than one lineage, largest first, and totals their
+// sizes.  An image holding a layer that one of its ancestors holds too has
+// inherited it, so only the ancestor counts.
+func duplicateLayers(images []Image, sizeFormat string) string {
+	parents := make(map[string]string)
+	for _, image := range images {
+		parents[image.Id] = image.ParentId
+	}
+
+	holders := make(map[string][]Image)
+	layers := make(map[string]Layer)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		for _, layer := range image.History {
+			if layer.Size == 0 {
+				continue
+			}
+			key := layerKey(layer)
+			layers[key] = layer
+			holders[key] = append(holders[key], image)
+		}
+	}
+
+	var duplicates []*sharedLayer
+	var total int64
+	for key, holding := range holders {
+		shared := &sharedLayer{layer: layers[key]}
+		for _, image := range holding {
+			if !hasAncestorIn(image, holding, parents) {
+				shared.images = append(shared.images, image.RepoTags[0])
+			}
+		}
+		if len(shared.images) > 1 {
+			sort.Strings(shared.images)
+			duplicates = append(duplicates, shared)
+			total += shared.layer.Size
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		a, b := duplicates[i], duplicates[j]
+		if a.layer.Size != b.layer.Size {
+			return a.layer.Size > b.layer.Size
+		}
+		if createdBy(a.layer, false) != createdBy(b.layer, false) {
+			return createdBy(a.layer, false) < createdBy(b.layer, false)
+		}
+		return strings.Join(a.images, ",") < strings.Join(b.images, ",")
+	})
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SIZE\tIMAGES\tCREATED BY")
+	for _, shared := range duplicates {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", formatSize(shared.layer.Size, sizeFormat), strings.Join(shared.images, ", "), createdBy(shared.layer, false))
+	}
+	writer.Flush()
+	buffer.WriteString(fmt.Sprintf("%d layers shared between separate lineages, %s in total\n", len(duplicates), formatSize(total, sizeFormat)))
+
+	return buffer.String()
+}
+
+// hasAncestorIn tells whether any of the candidates is an ancestor of the
+// image, following the parent ids.
+func hasAncestorIn(image Image, candidates []Image, parents map[string]string) bool {
+	ids := make(map[string]bool)
+	for _, candidate := range candidates {
+		ids[candidate.Id] = true
+	}
+	seen := make(map[string]bool)
+	for id := parents[image.Id]; id != "" && !seen[id]; id = parents[id] {
+		if ids[id] {
+			return true
+		}
+		seen[id] = true
+	}
+	return false
+}
+
+// sharedSavings compares the space the images would take if nothing were
+// shared (the virtual sizes of the tagged and childless images) with what
+// they take on disk.  On disk, each layer counts once: by history when every
+// such image has one, and otherwise by each image's own size on top of its
+// parent.
+func sharedSavings(images []Image, sizeFormat string) string {
+	byParent := collectChildren(&images)
+
+	var unshared, onDisk int64
+	byHistory := true
+	for _, image := range images {
+		if isTagged(image) || len(byParent[image.Id]) == 0 {
+			unshared += image.VirtualSize
+			if len(image.History) == 0 {
+				byHistory = false
+			}
+		}
+	}
+
+	if byHistory {
+		seen := make(map[string]bool)
+		for _, image := range images {
+			for _, layer := range image.History {
+				if key := layerKey(layer); !seen[key] {
+					seen[key] = true
+					onDisk += layer.Size
+				}
+			}
+		}
+	} else {
+		for _, image := range images {
+			onDisk += image.Size
+		}
+	}
+
+	return fmt.Sprintf("Shared savings: %s (%s unshared, %s on disk)\n", formatSize(unshared-onDisk, sizeFormat), formatSize(unshared, sizeFormat), formatSize(onDisk, sizeFormat))
+}
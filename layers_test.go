@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_DuplicateLayers(t *testing.T) {
+	rootfs := Layer{Id: "<missing>", CreatedBy: "/bin/sh -c #(nop) ADD file:rootfs in /", Size: 5000000, Created: 100}
+	deps := Layer{Id: "<missing>", CreatedBy: "/bin/sh -c apt-get install -y libssl", Size: 2000000, Created: 200}
+	empty := Layer{Id: "<missing>", CreatedBy: "/bin/sh -c #(nop) CMD [\"sh\"]", Created: 101}
+
+	images := []Image{
+		{Id: "app", RepoTags: []string{"app:latest"}, History: []Layer{{Id: "app", CreatedBy: "make", Size: 300, Created: 300}, deps, empty, rootfs}},
+		{Id: "web", RepoTags: []string{"web:latest", "web:1"}, History: []Layer{{Id: "web", CreatedBy: "npm install", Size: 400, Created: 400}, deps, empty, rootfs}},
+		{Id: "db", RepoTags: []string{"db:latest"}, History: []Layer{{Id: "db", CreatedBy: "initdb", Size: 500, Created: 500}, empty, rootfs}},
+		{Id: "layer", RepoTags: []string{"<none>:<none>"}, History: []Layer{deps, empty, rootfs}},
+		// inherits every layer of app, so it is not a separate lineage
+		{Id: "debug", ParentId: "app", RepoTags: []string{"app:debug"}, History: []Layer{{Id: "debug", CreatedBy: "apt-get install -y gdb", Size: 700, Created: 600}, {Id: "app", CreatedBy: "make", Size: 300, Created: 300}, deps, empty, rootfs}},
+	}
+
+	result := duplicateLayers(images, "si")
+	expected := `SIZE    IMAGES                             CREATED BY
+5.0 MB  app:latest, db:latest, web:latest  ADD file:rootfs in /
+2.0 MB  app:latest, web:latest             apt-get install -y libssl
+2 layers shared between separate lineages, 7.0 MB in total
+`
+	if result != expected {
+		t.Fatalf("duplicate layers were\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_SharedSavings(t *testing.T) {
+	base := Layer{Id: "<missing>", CreatedBy: "ADD rootfs", Size: 5000, Created: 1}
+	images := []Image{
+		{Id: "app", RepoTags: []string{"app:latest"}, VirtualSize: 5300, History: []Layer{{Id: "app", CreatedBy: "COPY app", Size: 300, Created: 3}, base}},
+		{Id: "api", RepoTags: []string{"api:latest"}, VirtualSize: 5200, History: []Layer{{Id: "api", CreatedBy: "COPY api", Size: 200, Created: 2}, base}},
+	}
+	if result := sharedSavings(images, "si"); result != "Shared savings: 5.0 KB (10.5 KB unshared, 5.5 KB on disk)\n" {
+		t.Fatalf("unexpected savings by history '%s'", result)
+	}
+
+	// without histories, the parent chain gives each layer's size once
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	if result := sharedSavings(*im, "raw"); result != "Shared savings: 1375106928 (2139660392 unshared, 764553464 on disk)\n" {
+		t.Fatalf("unexpected savings by parent '%s'", result)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type ndjsonNode struct {
+	Id       string   `json:"id"`
+	ParentId string   `json:"parentId,omitempty"`
+	Depth    int      `json:"depth"`
+	Size     int64    `json:"size"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// writeNDJSON writes one JSON object per line for every image in the tree,
+// parents before their children, as the tree is walked.
+func writeNDJSON(w io.Writer, roots []Image, byParent map[string][]Image, opts TreeOptions) error {
+	var walk func(images []Image, depth int) error
+	walk = func(images []Image, depth int) error {
+		for _, image := range images {
+			node := ndjsonNode{Id: image.Id, ParentId: image.ParentId, Depth: depth, Size: image.VirtualSize}
+			if opts.Incremental {
+				node.Size = image.Size
+			}
+			if isTagged(image) {
+				node.Tags = image.RepoTags
+			}
+			encoded, err := json.Marshal(node)
+			if err == nil {
+				encoded, err = opts.JSONStyle.apply(encoded)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(append(encoded, '\n')); err != nil {
+				return err
+			}
+			if err := walk(byParent[image.Id], depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return walk(roots, 0)
+}
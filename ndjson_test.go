@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_NDJSON(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+
+	var buffer bytes.Buffer
+	if err := writeNDJSON(&buffer, roots, byParent, TreeOptions{Incremental: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	expected := []struct {
+		id    string
+		depth int
+	}{
+		{"4c1208b690c6", 0}, {"626147582d2a", 1}, {"574c5faaf8d4", 2}, {"aaf8d4d1bcca", 3}, {"735f5db56261", 1}, {"c87be8e5e697", 2},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got '%s'", len(expected), buffer.String())
+	}
+
+	seen := make(map[string]bool)
+	for index, line := range lines {
+		var node ndjsonNode
+		if err := json.Unmarshal([]byte(line), &node); err != nil {
+			t.Fatalf("line %d is not valid JSON: %s", index, line)
+		}
+		if truncate(node.Id) != expected[index].id || node.Depth != expected[index].depth {
+			t.Fatalf("line %d was %s at depth %d, expected %s at depth %d", index, truncate(node.Id), node.Depth, expected[index].id, expected[index].depth)
+		}
+		if len(node.ParentId) > 0 && !seen[node.ParentId] {
+			t.Fatalf("line %d came before its parent", index)
+		}
+		seen[node.Id] = true
+	}
+
+	if lines[2] != `{"id":"574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870","parentId":"626147582d2ae3735f5db5f2c87be8e5e697c088574c5faaf8d4d1bccab99470","depth":2,"size":30000000,"tags":["base:latest"]}` {
+		t.Fatalf("unexpected node line %s", lines[2])
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// palette holds the colors of one --color-scheme: the ends of the age
+// gradient and the colors picked per repository.
+type palette struct {
+	fresh, old [3]float64
+	repos      []string
+}
+
+var palettes = map[string]palette{
+	"default": {
+		fresh: [3]float64{0x66, 0xcc, 0x66},
+		old:   [3]float64{0xbb, 0xbb, 0xbb},
+		repos: []string{"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462", "#b3de69", "#fccde5", "#bc80bd", "#ccebc5"},
+	},
+	"viridis": {
+		fresh: [3]float64{0xfd, 0xe7, 0x25},
+		old:   [3]float64{0x44, 0x01, 0x54},
+		repos: []string{"#440154", "#482878", "#3e4989", "#31688e", "#26828e", "#1f9e89", "#35b779", "#6ece58", "#b5de2b", "#fde725"},
+	},
+	// Okabe-Ito colors, told apart with the common kinds of color blindness
+	"colorblind": {
+		fresh: [3]float64{0x00, 0x72, 0xb2},
+		old:   [3]float64{0xe6, 0x9f, 0x00},
+		repos: []string{"#e69f00", "#56b4e9", "#009e73", "#f0e442", "#0072b2", "#d55e00", "#cc79a7", "#999999"},
+	},
+	"grayscale": {
+		fresh: [3]float64{0x40, 0x40, 0x40},
+		old:   [3]float64{0xe0, 0xe0, 0xe0},
+		repos: []string{"#f0f0f0", "#d9d9d9", "#bdbdbd", "#969696", "#737373"},
+	},
+}
+
+// lookupPalette returns the named palette, the default one when name is empty
+// or unknown.  --color-scheme only accepts the names of palettes.
+func lookupPalette(name string) palette {
+	if chosen, exists := palettes[name]; exists {
+		return chosen
+	}
+	return palettes["default"]
+}
+
+// gradient interpolates between the fresh (0.0) and old (1.0) colors.
+func (p palette) gradient(fraction float64) string {
+	var color [3]int
+	for i := range color {
+		color[i] = int(p.fresh[i] + (p.old[i]-p.fresh[i])*fraction + 0.5)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", color[0], color[1], color[2])
+}
+
+// repoColor picks a color for the repository, the same one every time.
+func (p palette) repoColor(reponame string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(reponame))
+	return p.repos[hash.Sum32()%uint32(len(p.repos))]
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func Test_ColorSchemes(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	oldest := (*im)[len(*im)-1].Id
+
+	seen := make(map[string]string)
+	for name := range palettes {
+		colorScheme := lookupPalette(name)
+		colors := ageColors(*im, colorScheme)[oldest] + " " + colorScheme.repoColor("foo")
+		if other, exists := seen[colors]; exists {
+			t.Fatalf("schemes %s and %s produced the same colors %s", name, other, colors)
+		}
+		seen[colors] = name
+	}
+
+	if colorScheme := lookupPalette(""); colorScheme.gradient(0) != "#66cc66" || colorScheme.gradient(1) != "#bbbbbb" {
+		t.Fatal("an empty scheme did not pick the default palette")
+	}
+
+	// the flag offers exactly the palettes there are
+	field, _ := reflect.TypeOf(ImagesCommand{}).FieldByName("ColorScheme")
+	choices := regexp.MustCompile(`choice:"(\w+)"`).FindAllStringSubmatch(string(field.Tag), -1)
+	if len(choices) != len(palettes) {
+		t.Fatalf("--color-scheme offers %d choices for %d palettes", len(choices), len(palettes))
+	}
+	for _, choice := range choices {
+		if _, exists := palettes[choice[1]]; !exists {
+			t.Errorf("--color-scheme offers unknown palette %s", choice[1])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"golang.org/x/term"
+)
+
+// progressFrames are drawn in turn while waiting on the daemon.
+var progressFrames = []string{"|", "/", "-", "\\"}
+
+// isTerminal is swapped out in tests.
+var isTerminal = term.IsTerminal
+
+// progressWriter picks where progress is drawn: stderr when it is a
+// terminal, or nowhere when it is redirected or with --quiet.
+func progressWriter(stderr *os.File, quiet bool) io.Writer {
+	if quiet || !isTerminal(int(stderr.Fd())) {
+		return nil
+	}
+	return stderr
+}
+
+// progress redraws a spinner, and a count when the total is known, on a
+// single line until done is called.  Without a writer it does nothing.
+type progress struct {
+	w     io.Writer
+	label string
+	total int
+
+	mutex sync.Mutex
+	count int
+	frame int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func startProgress(w io.Writer, label string, total int) *progress {
+	p := &progress{w: w, label: label, total: total}
+	if w == nil {
+		return p
+	}
+
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	p.draw()
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.draw()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// step counts one more item as finished.
+func (p *progress) step() {
+	p.mutex.Lock()
+	p.count++
+	p.mutex.Unlock()
+}
+
+// done stops the spinner and clears its line.
+func (p *progress) done() {
+	if p.w == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	fmt.Fprint(p.w, "\r\x1b[K")
+}
+
+func (p *progress) draw() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	fmt.Fprintf(p.w, "\r%s %s", progressFrames[p.frame%len(progressFrames)], p.label)
+	if p.total > 0 {
+		fmt.Fprintf(p.w, " %d/%d", p.count, p.total)
+	}
+	p.frame++
+}
+
+// progressHistoryClient counts each history fetched through it.
+type progressHistoryClient struct {
+	client   historyClient
+	progress *progress
+}
+
+func (c progressHistoryClient) ImageHistory(name string) ([]docker.ImageHistory, error) {
+	defer c.progress.step()
+	return c.client.ImageHistory(name)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/term"
+)
+
+func Test_ProgressWriter(t *testing.T) {
+	defer func() { isTerminal = term.IsTerminal }()
+
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	isTerminal = func(fd int) bool { return false }
+	if w := progressWriter(stderr, false); w != nil {
+		t.Fatal("progress drawn although stderr is not a terminal")
+	}
+
+	isTerminal = func(fd int) bool { return true }
+	if w := progressWriter(stderr, true); w != nil {
+		t.Fatal("progress drawn despite --quiet")
+	}
+	if w := progressWriter(stderr, false); w != stderr {
+		t.Fatal("progress not drawn to stderr on a terminal")
+	}
+}
+
+func Test_Progress(t *testing.T) {
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+	captured, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = captured
+
+	var stderr bytes.Buffer
+	p := startProgress(&stderr, "Fetching image histories", 3)
+	p.step()
+	p.done()
+
+	if !strings.HasPrefix(stderr.String(), "\r| Fetching image histories 0/3") || !strings.HasSuffix(stderr.String(), "\r\x1b[K") {
+		t.Fatalf("unexpected progress output %q", stderr.String())
+	}
+	if written, _ := ioutil.ReadFile(captured.Name()); len(written) > 0 {
+		t.Fatalf("progress wrote %q to stdout", written)
+	}
+
+	// without a writer, progress is a no-op
+	p = startProgress(nil, "Listing images", 0)
+	p.step()
+	p.done()
+}
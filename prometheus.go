@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusMetrics writes the image metrics in the Prometheus text
+// exposition format: the virtual size of every image under each of its tags
+// (untagged images with empty repo and tag labels), the image count and the
+// space reclaimable images take up.
+func prometheusMetrics(images []Image) string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("# HELP dockviz_image_size_bytes Virtual size of the image.\n")
+	buffer.WriteString("# TYPE dockviz_image_size_bytes gauge\n")
+	for _, image := range images {
+		repotags := []string{""}
+		if isTagged(image) {
+			repotags = image.RepoTags
+		}
+		for _, repotag := range repotags {
+			reponame, tagname := splitRepoTag(repotag)
+			buffer.WriteString(fmt.Sprintf("dockviz_image_size_bytes{id=\"%s\",repo=\"%s\",tag=\"%s\"} %d\n",
+				prometheusEscaper.Replace(image.Id), prometheusEscaper.Replace(reponame), prometheusEscaper.Replace(tagname), image.VirtualSize))
+		}
+	}
+
+	buffer.WriteString("# HELP dockviz_images_total Number of images.\n")
+	buffer.WriteString("# TYPE dockviz_images_total gauge\n")
+	buffer.WriteString(fmt.Sprintf("dockviz_images_total %d\n", computeStats(images).ImageCount))
+
+	var reclaimable int64
+	for _, image := range reclaimableImages(images) {
+		reclaimable += image.Size
+	}
+	buffer.WriteString("# HELP dockviz_reclaimable_bytes Space taken by untagged images nothing depends on.\n")
+	buffer.WriteString("# TYPE dockviz_reclaimable_bytes gauge\n")
+	buffer.WriteString(fmt.Sprintf("dockviz_reclaimable_bytes %d\n", reclaimable))
+
+	return buffer.String()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_PrometheusMetrics(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["registry:5000/app:1.0","app:we\"ird\\tag"],"VirtualSize":300,"Size":300},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"VirtualSize":350,"Size":50},{"Id":"3333333333333333","RepoTags":["<none>:<none>"],"VirtualSize":70,"Size":70,"Containers":1}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	expected := `# HELP dockviz_image_size_bytes Virtual size of the image.
+# TYPE dockviz_image_size_bytes gauge
+dockviz_image_size_bytes{id="1111111111111111",repo="registry:5000/app",tag="1.0"} 300
+dockviz_image_size_bytes{id="1111111111111111",repo="app",tag="we\"ird\\tag"} 300
+dockviz_image_size_bytes{id="2222222222222222",repo="",tag=""} 350
+dockviz_image_size_bytes{id="3333333333333333",repo="",tag=""} 70
+# HELP dockviz_images_total Number of images.
+# TYPE dockviz_images_total gauge
+dockviz_images_total 3
+# HELP dockviz_reclaimable_bytes Space taken by untagged images nothing depends on.
+# TYPE dockviz_reclaimable_bytes gauge
+dockviz_reclaimable_bytes 50
+`
+	if result := prometheusMetrics(*im); result != expected {
+		t.Fatalf("metrics were\n%s\nexpected\n%s", result, expected)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+)
+
+// registryTags lists the tags of a repository through the registry's v2 API.
+// Registries that need authentication are not supported.
+func registryTags(client *http.Client, registry string, repo string) ([]string, error) {
+	if !strings.Contains(registry, "://") {
+		registry = "https://" + registry
+	}
+	url := fmt.Sprintf("%s/v2/%s/tags/list", strings.TrimSuffix(registry, "/"), repo)
+
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the tags of %s: %w", repo, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to list the tags of %s: %s returned %s", repo, url, response.Status)
+	}
+
+	var listing struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("Unable to read the tags of %s: %w", repo, err)
+	}
+
+	return listing.Tags, nil
+}
+
+// registryStatus reports, for each tag the registry has for repo, whether an
+// image with that tag exists locally.  Local images may be tagged with the
+// registry host in front of the repository or without it.
+func registryStatus(tags []string, images []Image, registry string, repo string, idLength int) string {
+	host := registry
+	if index := strings.Index(host, "://"); index >= 0 {
+		host = host[index+3:]
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	local := make(map[string]string)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		for _, repotag := range image.RepoTags {
+			reponame, tagname := splitRepoTag(repotag)
+			if reponame == repo || reponame == host+"/"+repo {
+				local[tagname] = image.Id
+			}
+		}
+	}
+
+	sorted := append([]string{}, tags...)
+	sortTags(sorted, false)
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TAG\tSTATUS\tIMAGE")
+	for _, tag := range sorted {
+		if id, exists := local[tag]; exists {
+			fmt.Fprintf(writer, "%s\tpresent\t%s\n", tag, truncateTo(id, idLength))
+		} else {
+			fmt.Fprintf(writer, "%s\tabsent\n", tag)
+		}
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
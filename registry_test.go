@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_RegistryStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/team/app/tags/list" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"name":"team/app","tags":["1.1","latest","1.0","2.0"]}`))
+	}))
+	defer server.Close()
+
+	tags, err := registryTags(server.Client(), server.URL+"/", "team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	json := `[{"Id":"1111111111111111","RepoTags":["team/app:latest","team/app:1.1"]},{"Id":"2222222222222222","RepoTags":["` + host + `/team/app:1.0"]},{"Id":"3333333333333333","RepoTags":["other/app:2.0","team/app:local"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	expected := `TAG     STATUS   IMAGE
+latest  present  111111111111
+1.0     present  222222222222
+1.1     present  111111111111
+2.0     absent
+`
+	if result := registryStatus(tags, *im, server.URL, "team/app", 12); result != expected {
+		t.Fatalf("registry status was\n%s\nexpected\n%s", result, expected)
+	}
+
+	if _, err := registryTags(server.Client(), server.URL, "team/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected an error for a missing repository, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// graphvizCommand builds the command used to run Graphviz; tests replace it.
+var graphvizCommand = exec.Command
+
+// renderDot pipes dot source through Graphviz, returning the rendered output
+// in the requested format (e.g. svg or png).
+func renderDot(dot string, format string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := graphvizCommand("dot", "-T"+format)
+	cmd.Stdin = strings.NewReader(dot)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("Unable to run Graphviz, please make sure 'dot' is installed and in your PATH: %s", err)
+		}
+		return nil, fmt.Errorf("Graphviz failed to render %s: %s\n%s", format, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// writeRendered renders dot source and writes it to the output path, or to
+// stdout when no path is given.  Nothing is written if rendering fails.
+func writeRendered(dot string, format string, output string) error {
+	rendered, err := renderDot(dot, format)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(rendered, output)
+}
+
+// dryRunRendered logs what writeRendered would do, without running Graphviz.
+// The size is that of the dot source, as the rendered size isn't known.
+func dryRunRendered(log io.Writer, dot string, format string, output string) {
+	fmt.Fprintf(log, "Dry run: would render %d bytes of dot as %s to %s\n", len(dot), format, outputName(output))
+}
+
+// dryRunOutput logs what writeOutput would do, without writing anything.
+func dryRunOutput(log io.Writer, data []byte, format string, output string) {
+	fmt.Fprintf(log, "Dry run: would write %d bytes of %s to %s\n", len(data), format, outputName(output))
+}
+
+func outputName(output string) string {
+	if len(output) == 0 {
+		return "stdout"
+	}
+	return output
+}
+
+// outputFormats maps output file extensions to the format written for them.
+var outputFormats = map[string]string{
+	".dot":  "dot",
+	".gv":   "dot",
+	".json": "json",
+	".svg":  "svg",
+	".png":  "png",
+}
+
+// outputFormat infers the format to write from the output path's extension.
+func outputFormat(output string) (string, error) {
+	extension := strings.ToLower(filepath.Ext(output))
+	if format, exists := outputFormats[extension]; exists {
+		return format, nil
+	}
+	return "", fmt.Errorf("Unable to tell the output format of '%s' from its extension, please choose one (e.g. --dot, --json or --render svg)", output)
+}
+
+// writeOutput writes data to the output path, or to stdout when no path is
+// given.
+func writeOutput(data []byte, output string) error {
+	if len(output) == 0 {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	return ioutil.WriteFile(output, data, 0644)
+}
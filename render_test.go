@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubGraphviz runs this test binary in place of Graphviz; the helper process
+// echoes its arguments and stdin back so tests can inspect what was passed.
+func stubGraphviz(t *testing.T, mode string) {
+	original := graphvizCommand
+	graphvizCommand = func(name string, args ...string) *exec.Cmd {
+		helperArgs := append([]string{"-test.run=Test_GraphvizHelperProcess", "--", name}, args...)
+		cmd := exec.Command(os.Args[0], helperArgs...)
+		cmd.Env = append(os.Environ(), "DOCKVIZ_GRAPHVIZ_HELPER="+mode)
+		return cmd
+	}
+	t.Cleanup(func() { graphvizCommand = original })
+}
+
+func Test_GraphvizHelperProcess(t *testing.T) {
+	mode := os.Getenv("DOCKVIZ_GRAPHVIZ_HELPER")
+	if len(mode) == 0 {
+		return
+	}
+
+	if mode == "fail" {
+		fmt.Fprintln(os.Stderr, "Error: <stdin>: syntax error in line 1")
+		os.Exit(1)
+	}
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	input, _ := ioutil.ReadAll(os.Stdin)
+	fmt.Printf("%s\n%s", strings.Join(args[1:], " "), input)
+	os.Exit(0)
+}
+
+func Test_RenderDot(t *testing.T) {
+	stubGraphviz(t, "echo")
+
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	dot := jsonToDot(collectRoots(im), collectChildren(im), DotOptions{})
+
+	for _, format := range []string{"svg", "png"} {
+		rendered, err := renderDot(dot, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "dot -T" + format + "\n" + dot
+		if string(rendered) != expected {
+			t.Fatalf("graphviz got '%s', expected '%s'", rendered, expected)
+		}
+	}
+
+	output := filepath.Join(t.TempDir(), "images.svg")
+	if err := writeRendered(dot, "svg", output); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := ioutil.ReadFile(output)
+	if !strings.HasPrefix(string(written), "dot -Tsvg\ndigraph docker {") {
+		t.Fatalf("rendered file content was '%s'", written)
+	}
+}
+
+func Test_RenderDotErrors(t *testing.T) {
+	stubGraphviz(t, "fail")
+
+	output := filepath.Join(t.TempDir(), "images.png")
+	err := writeRendered("digraph docker {", "png", output)
+	if err == nil || !strings.Contains(err.Error(), "Graphviz failed to render png") || !strings.Contains(err.Error(), "syntax error") {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+		t.Fatal("output file was written despite the render error")
+	}
+
+	graphvizCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("dockviz-no-such-graphviz", args...)
+	}
+	_, err = renderDot("digraph docker {}", "svg")
+	if err == nil || !strings.Contains(err.Error(), "make sure 'dot' is installed") {
+		t.Fatalf("expected a missing Graphviz error, got %v", err)
+	}
+}
+
+func Test_DryRun(t *testing.T) {
+	stubGraphviz(t, "fail")
+
+	output := filepath.Join(t.TempDir(), "images.svg")
+	var log bytes.Buffer
+	dryRunRendered(&log, "digraph docker {}\n", "svg", output)
+	dryRunOutput(&log, []byte("<svg/>"), "svg", "")
+
+	expected := "Dry run: would render 18 bytes of dot as svg to " + output + "\n" +
+		"Dry run: would write 6 bytes of svg to stdout\n"
+	if log.String() != expected {
+		t.Fatalf("dry run logged\n%s\nexpected\n%s", log.String(), expected)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatal("dry run created the output file")
+	}
+}
+
+func Test_DryRunWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	commands := []ImagesCommand{
+		{Output: filepath.Join(dir, "x.dot")},
+		{Output: filepath.Join(dir, "x.json")},
+		{Dot: true, SplitByRepo: filepath.Join(dir, "dots")},
+		{Prometheus: filepath.Join(dir, "metrics.prom")},
+	}
+	for _, command := range commands {
+		command.DryRun, command.BaseName, command.TruncLength = true, "base", 12
+		written, err := executeImages(t, command, treeJSON)
+		if err != nil || len(written) > 0 {
+			t.Fatalf("dry run of %+v printed '%s' (%v)", command, written, err)
+		}
+	}
+
+	if entries, _ := ioutil.ReadDir(dir); len(entries) > 0 {
+		t.Fatalf("dry run created %s", entries[0].Name())
+	}
+}
+
+func Test_OutputFormat(t *testing.T) {
+	formats := map[string]string{
+		"graph.dot":       "dot",
+		"tree.json":       "json",
+		"images.svg":      "svg",
+		"out/images.PNG":  "png",
+		"shared.graph.gv": "dot",
+	}
+	for output, expected := range formats {
+		if format, err := outputFormat(output); err != nil || format != expected {
+			t.Errorf("'%s' gave format '%s' (%v), expected '%s'", output, format, err, expected)
+		}
+	}
+
+	for _, output := range []string{"images.html", "images.csv", "images"} {
+		if _, err := outputFormat(output); err == nil || !strings.Contains(err.Error(), "please choose one") {
+			t.Errorf("expected an error for '%s', got %v", output, err)
+		}
+	}
+}
+
+func Test_OutputModeSelected(t *testing.T) {
+	if outputModeSelected(ImagesCommand{Output: "images.svg"}) {
+		t.Fatal("no output mode was chosen, the extension should pick one")
+	}
+	for _, cmd := range []ImagesCommand{{Tree: true}, {SummaryOnly: true}, {Count: true}, {Verify: true}} {
+		if !outputModeSelected(cmd) {
+			t.Errorf("output mode of %+v not noticed", cmd)
+		}
+	}
+}
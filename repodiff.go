@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// repoDiff compares the tags of two repositories: the tags only one of them
+// has, and the tags both have, split by whether they point at the same image.
+func repoDiff(images []Image, repoA string, repoB string, idLength int) string {
+	tagsA, tagsB := repoTagIds(images, repoA), repoTagIds(images, repoB)
+
+	var onlyA, onlyB, same, different []string
+	for tag, id := range tagsA {
+		if otherId, exists := tagsB[tag]; !exists {
+			onlyA = append(onlyA, tag)
+		} else if otherId == id {
+			same = append(same, tag)
+		} else {
+			different = append(different, fmt.Sprintf("%s (%s vs %s)", tag, truncateTo(id, idLength), truncateTo(otherId, idLength)))
+		}
+	}
+	for tag := range tagsB {
+		if _, exists := tagsA[tag]; !exists {
+			onlyB = append(onlyB, tag)
+		}
+	}
+
+	var buffer bytes.Buffer
+	sections := []struct {
+		heading string
+		tags    []string
+	}{
+		{fmt.Sprintf("Only in %s:", repoA), onlyA},
+		{fmt.Sprintf("Only in %s:", repoB), onlyB},
+		{"Same image in both:", same},
+		{"Different images:", different},
+	}
+	for _, section := range sections {
+		sort.Strings(section.tags)
+		buffer.WriteString(section.heading + "\n")
+		for _, tag := range section.tags {
+			buffer.WriteString("  " + tag + "\n")
+		}
+	}
+
+	return buffer.String()
+}
+
+// repoTagIds maps the tags of a repository to the image ids they point at.
+func repoTagIds(images []Image, repo string) map[string]string {
+	tags := make(map[string]string)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		for _, repotag := range image.RepoTags {
+			if reponame, tagname := splitRepoTag(repotag); reponame == repo {
+				tags[tagname] = image.Id
+			}
+		}
+	}
+
+	return tags
+}
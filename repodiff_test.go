@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_RepoDiff(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["app:latest","app-next:latest"]},{"Id":"2222222222222222","RepoTags":["app:1.0","app:1.1"]},{"Id":"3333333333333333","RepoTags":["app:1.2"]},{"Id":"4444444444444444","RepoTags":["app-next:1.2","app-next:2.0"]},{"Id":"5555555555555555","RepoTags":["other:1.0"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := repoDiff(*im, "app", "app-next", 12)
+	expected := `Only in app:
+  1.0
+  1.1
+Only in app-next:
+  2.0
+Same image in both:
+  latest
+Different images:
+  1.2 (333333333333 vs 444444444444)
+`
+	if result != expected {
+		t.Fatalf("repo diff was\n%s\nexpected\n%s", result, expected)
+	}
+}
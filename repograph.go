@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// reposToDot draws one node per repository, with an edge from each
+// repository to those with images built on one of its images.
+func reposToDot(images []Image, opts DotOptions) string {
+	byId := make(map[string]Image)
+	for _, image := range images {
+		byId[image.Id] = image
+	}
+
+	repos := make(map[string]bool)
+	edges := make(map[[2]string]bool)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+
+		// the nearest tagged ancestor is what the image was built on
+		var bases []string
+		for parent, exists := byId[image.ParentId]; exists; parent, exists = byId[parent.ParentId] {
+			if isTagged(parent) {
+				bases = imageRepos(parent)
+				break
+			}
+		}
+
+		for _, reponame := range imageRepos(image) {
+			repos[reponame] = true
+			for _, base := range bases {
+				if base != reponame {
+					edges[[2]string{base, reponame}] = true
+				}
+			}
+		}
+	}
+
+	derived := make(map[string]bool)
+	var sortedEdges [][2]string
+	for edge := range edges {
+		derived[edge[1]] = true
+		sortedEdges = append(sortedEdges, edge)
+	}
+	sort.Slice(sortedEdges, func(i, j int) bool {
+		if sortedEdges[i][0] != sortedEdges[j][0] {
+			return sortedEdges[i][0] < sortedEdges[j][0]
+		}
+		return sortedEdges[i][1] < sortedEdges[j][1]
+	})
+	var sortedRepos []string
+	for reponame := range repos {
+		sortedRepos = append(sortedRepos, reponame)
+	}
+	sort.Strings(sortedRepos)
+
+	var buffer bytes.Buffer
+	writeDotHeader(&buffer, opts)
+	for _, reponame := range sortedRepos {
+		if !derived[reponame] {
+			buffer.WriteString(fmt.Sprintf(" %s -> \"%s\" [style=invis]\n", opts.base(), dotEscape(reponame)))
+		}
+	}
+	for _, edge := range sortedEdges {
+		buffer.WriteString(fmt.Sprintf(" \"%s\" -> \"%s\"\n", dotEscape(edge[0]), dotEscape(edge[1])))
+	}
+	for _, reponame := range sortedRepos {
+		buffer.WriteString(fmt.Sprintf(" \"%s\" [label=\"%s\",shape=box,fillcolor=\"paleturquoise\",style=\"filled,rounded\"];\n", dotEscape(reponame), dotEscape(reponame)))
+	}
+	buffer.WriteString(fmt.Sprintf(" %s [style=invisible]\n}\n", opts.base()))
+
+	return buffer.String()
+}
+
+// imageRepos lists the repositories an image is tagged in, each once.
+func imageRepos(image Image) []string {
+	var repos []string
+	seen := make(map[string]bool)
+	for _, repotag := range image.RepoTags {
+		if repotag == "<none>:<none>" {
+			continue
+		}
+		reponame, _ := splitRepoTag(repotag)
+		if !seen[reponame] {
+			seen[reponame] = true
+			repos = append(repos, reponame)
+		}
+	}
+	return repos
+}
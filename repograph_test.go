@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_ReposToDot(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["debian:11","debian:12"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:latest","app:1.0"]},{"Id":"4444444444444444","ParentId":"3333333333333333","RepoTags":["app:1.1","worker:latest"]},{"Id":"5555555555555555","RepoTags":["alpine:3"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	result := reposToDot(*im, DotOptions{})
+	expected := `digraph docker {
+ base -> "alpine" [style=invis]
+ base -> "debian" [style=invis]
+ "app" -> "worker"
+ "debian" -> "app"
+ "alpine" [label="alpine",shape=box,fillcolor="paleturquoise",style="filled,rounded"];
+ "app" [label="app",shape=box,fillcolor="paleturquoise",style="filled,rounded"];
+ "debian" [label="debian",shape=box,fillcolor="paleturquoise",style="filled,rounded"];
+ "worker" [label="worker",shape=box,fillcolor="paleturquoise",style="filled,rounded"];
+ base [style=invisible]
+}
+`
+	if result != expected {
+		t.Fatalf("repository graph was\n%s\nexpected\n%s", result, expected)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// jsonSchema describes the documents --json writes (or, with ndjson, each
+// line --ndjson writes) as a JSON Schema, generated from the structs that are
+// encoded so the two stay in sync.
+func jsonSchema(ndjson bool, style jsonStyle) (string, error) {
+	definitions := make(map[string]interface{})
+	schema := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+	}
+	if ndjson {
+		schema["title"] = "dockviz images --ndjson line"
+		schema["$ref"] = typeSchema(reflect.TypeOf(ndjsonNode{}), style, definitions)["$ref"]
+	} else {
+		schema["title"] = "dockviz images --json"
+		schema["type"] = "array"
+		schema["items"] = typeSchema(reflect.TypeOf(jsonNode{}), style, definitions)
+	}
+	schema["definitions"] = definitions
+
+	encoded, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(encoded) + "\n", nil
+}
+
+// typeSchema maps a Go type to its schema, adding structs to definitions and
+// referring to them there, which also covers recursive types.
+func typeSchema(t reflect.Type, style jsonStyle, definitions map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), style, definitions)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+		if _, defined := definitions[t.Name()]; defined {
+			return ref
+		}
+		properties := make(map[string]interface{})
+		required := []string{}
+		definition := map[string]interface{}{"type": "object", "additionalProperties": false}
+		definitions[t.Name()] = definition
+		for index := 0; index < t.NumField(); index++ {
+			field := t.Field(index)
+			tag := strings.Split(field.Tag.Get("json"), ",")
+			if tag[0] == "-" {
+				continue
+			}
+			name := tag[0]
+			if len(name) == 0 {
+				name = field.Name
+			}
+			if style.SnakeCase {
+				name = snakeCase(name)
+			}
+			properties[name] = typeSchema(field.Type, style, definitions)
+			if len(tag) == 1 && !style.OmitEmpty {
+				required = append(required, name)
+			}
+		}
+		definition["properties"] = properties
+		definition["required"] = required
+		return ref
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// validate checks a decoded JSON value against the parts of JSON Schema that
+// jsonSchema uses.
+func validate(value interface{}, schema map[string]interface{}, root map[string]interface{}, path string) error {
+	if ref, exists := schema["$ref"].(string); exists {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		return validate(value, root["definitions"].(map[string]interface{})[name].(map[string]interface{}), root, path)
+	}
+
+	switch schema["type"] {
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an array", path)
+		}
+		for index, item := range items {
+			if err := validate(item, schema["items"].(map[string]interface{}), root, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an object", path)
+		}
+		properties := schema["properties"].(map[string]interface{})
+		for _, name := range schema["required"].([]interface{}) {
+			if _, exists := object[name.(string)]; !exists {
+				return fmt.Errorf("%s lacks %s", path, name)
+			}
+		}
+		for name, property := range object {
+			propertySchema, known := properties[name]
+			if !known {
+				return fmt.Errorf("%s has unexpected %s", path, name)
+			}
+			if err := validate(property, propertySchema.(map[string]interface{}), root, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s is not a string", path)
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s is not an integer", path)
+		}
+	}
+	return nil
+}
+
+func Test_JSONSchema(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+
+	for _, style := range []jsonStyle{{}, {SnakeCase: true, OmitEmpty: true}} {
+		var schema map[string]interface{}
+		encoded, err := jsonSchema(false, style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.Unmarshal([]byte(encoded), &schema)
+
+		var output bytes.Buffer
+		writeTreeJSON(&output, roots, byParent, TreeOptions{JSONStyle: style}, true)
+		var document interface{}
+		json.Unmarshal(output.Bytes(), &document)
+		if err := validate(document, schema, schema, "$"); err != nil {
+			t.Fatalf("--json output does not match its schema: %s\n%s", err, output.String())
+		}
+
+		encoded, _ = jsonSchema(true, style)
+		schema = nil
+		json.Unmarshal([]byte(encoded), &schema)
+		output.Reset()
+		writeNDJSON(&output, roots, byParent, TreeOptions{JSONStyle: style})
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			var document interface{}
+			json.Unmarshal([]byte(line), &document)
+			if err := validate(document, schema, schema, "$"); err != nil {
+				t.Fatalf("--ndjson line does not match its schema: %s\n%s", err, line)
+			}
+		}
+	}
+
+	// a stray field is caught
+	var schema map[string]interface{}
+	encoded, _ := jsonSchema(false, jsonStyle{})
+	json.Unmarshal([]byte(encoded), &schema)
+	var document interface{}
+	json.Unmarshal([]byte(`[{"id":"1","size":1,"childrenCount":0,"parent":"2"}]`), &document)
+	if err := validate(document, schema, schema, "$"); err == nil {
+		t.Fatal("a document with an unknown field matched the schema")
+	}
+}
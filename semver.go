@@ -0,0 +1,131 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// semverTag matches tags like 1, 1.2, v1.2.3 and 1.2.3-rc1.
+var semverTag = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?$`)
+
+type semver struct {
+	parts      [3]int
+	prerelease string
+}
+
+// parseSemver parses a tag as a (possibly partial) semantic version.
+func parseSemver(tag string) (semver, bool) {
+	match := semverTag.FindStringSubmatch(tag)
+	if match == nil {
+		return semver{}, false
+	}
+
+	var version semver
+	for index := range version.parts {
+		if len(match[index+1]) > 0 {
+			version.parts[index], _ = strconv.Atoi(match[index+1])
+		}
+	}
+	version.prerelease = match[4]
+
+	return version, true
+}
+
+// compareSemver returns -1, 0 or 1 as a is lower than, equal to or higher than
+// b.  A prerelease sorts below the release it precedes.
+func compareSemver(a, b semver) int {
+	for index := range a.parts {
+		if a.parts[index] != b.parts[index] {
+			if a.parts[index] < b.parts[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+	return strings.Compare(a.prerelease, b.prerelease)
+}
+
+// sortTags orders a repository's tags by version, with tags that aren't
+// versions after them in lexical order.  The latest tag is pinned first, or
+// last with latestLast.
+func sortTags(tags []string, latestLast bool) {
+	rank := func(tag string) int {
+		switch {
+		case tag == "latest" && latestLast:
+			return 2
+		case tag == "latest":
+			return -1
+		}
+		return 0
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if rank(tags[i]) != rank(tags[j]) {
+			return rank(tags[i]) < rank(tags[j])
+		}
+		a, aOk := parseSemver(tags[i])
+		b, bOk := parseSemver(tags[j])
+		switch {
+		case aOk && bOk && compareSemver(a, b) != 0:
+			return compareSemver(a, b) < 0
+		case aOk != bOk:
+			return aOk
+		}
+		return tags[i] < tags[j]
+	})
+}
+
+// latestTags picks the tags to show per image when only the latest tag of
+// each repository is wanted: the repository's :latest tag, or its highest
+// semver tag when there is no :latest.  Repositories with neither keep all
+// of their tags.
+func latestTags(images []Image) map[string][]string {
+	best := make(map[string]string)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		for _, repotag := range image.RepoTags {
+			repo, tag := splitRepoTag(repotag)
+			current, seen := best[repo]
+			switch {
+			case !seen || tag == "latest":
+				best[repo] = tag
+			case current == "latest":
+			default:
+				version, ok := parseSemver(tag)
+				currentVersion, currentOk := parseSemver(current)
+				if ok && (!currentOk || compareSemver(version, currentVersion) > 0) {
+					best[repo] = tag
+				}
+			}
+		}
+	}
+
+	shown := make(map[string][]string)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		kept := []string{}
+		for _, repotag := range image.RepoTags {
+			repo, tag := splitRepoTag(repotag)
+			if _, ok := parseSemver(best[repo]); tag == best[repo] || (best[repo] != "latest" && !ok) {
+				kept = append(kept, repotag)
+			}
+		}
+		shown[image.Id] = kept
+	}
+
+	return shown
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_CompareSemver(t *testing.T) {
+	ordered := []string{"0.9", "1", "1.0.1", "v1.2.0-rc1", "1.2", "1.10.0"}
+	for index := 1; index < len(ordered); index++ {
+		lower, _ := parseSemver(ordered[index-1])
+		higher, _ := parseSemver(ordered[index])
+		if compareSemver(lower, higher) != -1 || compareSemver(higher, lower) != 1 {
+			t.Errorf("expected %s to sort below %s", ordered[index-1], ordered[index])
+		}
+	}
+
+	for _, tag := range []string{"latest", "1.2.3.4", "stable-1.0", ""} {
+		if _, ok := parseSemver(tag); ok {
+			t.Errorf("'%s' parsed as a version", tag)
+		}
+	}
+}
+
+func Test_LatestTags(t *testing.T) {
+	images := []Image{
+		{Id: "a", RepoTags: []string{"app:1.0", "app:latest", "tools:stable"}},
+		{Id: "b", RepoTags: []string{"app:2.0"}},
+		{Id: "c", RepoTags: []string{"lib:1.9", "lib:1.10-rc1"}},
+		{Id: "d", RepoTags: []string{"lib:1.10", "tools:edge"}},
+		{Id: "e", RepoTags: []string{"<none>:<none>"}},
+	}
+
+	expected := map[string][]string{
+		"a": {"app:latest", "tools:stable"},
+		"b": {},
+		"c": {},
+		"d": {"lib:1.10", "tools:edge"},
+	}
+	if shown := latestTags(images); !reflect.DeepEqual(shown, expected) {
+		t.Fatalf("latest tags were %v, expected %v", shown, expected)
+	}
+}
+
+func Test_SortTags(t *testing.T) {
+	tags := []string{"2.0.0", "stable", "1.10.0", "latest", "1.2.0", "edge", "1.2.0-rc1"}
+
+	sortTags(tags, false)
+	expected := []string{"latest", "1.2.0-rc1", "1.2.0", "1.10.0", "2.0.0", "edge", "stable"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("tags sorted as %v, expected %v", tags, expected)
+	}
+
+	sortTags(tags, true)
+	expected = []string{"1.2.0-rc1", "1.2.0", "1.10.0", "2.0.0", "edge", "stable", "latest"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("tags sorted as %v with latest last, expected %v", tags, expected)
+	}
+}
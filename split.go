@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// characters that are replaced when turning a repository into a filename
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// splitByRepo writes one dot file per repository into dir, each holding the
+// repository's images and their ancestors.  The paths written are returned.
+func splitByRepo(images []Image, dir string, opts DotOptions) ([]string, error) {
+	paths, dots := repoDotFiles(images, dir, opts)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for index, path := range paths {
+		if err := ioutil.WriteFile(path, []byte(dots[index]), 0644); err != nil {
+			return written, fmt.Errorf("Unable to write %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
+
+// repoDotFiles returns the path and dot graph of each file splitByRepo
+// writes, without writing them.  Repositories whose names turn into the same
+// filename get a numbered suffix.
+func repoDotFiles(images []Image, dir string, opts DotOptions) ([]string, []string) {
+	repos := make(map[string]bool)
+	for _, image := range images {
+		if isTagged(image) {
+			for _, repotag := range image.RepoTags {
+				reponame, _ := splitRepoTag(repotag)
+				repos[reponame] = true
+			}
+		}
+	}
+	var names []string
+	for reponame := range repos {
+		names = append(names, reponame)
+	}
+	sort.Strings(names)
+
+	var paths, dots []string
+	used := make(map[string]bool)
+	for _, reponame := range names {
+		lineage := repoLineage(images, reponame)
+		base := unsafeFilename.ReplaceAllString(reponame, "_")
+		filename := base
+		for suffix := 2; used[filename]; suffix++ {
+			filename = fmt.Sprintf("%s_%d", base, suffix)
+		}
+		used[filename] = true
+		paths = append(paths, filepath.Join(dir, filename+".dot"))
+		dots = append(dots, jsonToDot(collectRoots(&lineage), collectChildren(&lineage), opts))
+	}
+
+	return paths, dots
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_SplitByRepo(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["base:latest"]},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"]},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["registry.local:5000/team/app:1.0"]},{"Id":"4444444444444444","ParentId":"1111111111111111","RepoTags":["db:latest","db:9"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+	roots, byParent := prepareTree(im, nil, false)
+
+	dir := filepath.Join(t.TempDir(), "dots")
+	written, err := splitByRepo(flattenTree(roots, byParent), dir, DotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "base.dot"),
+		filepath.Join(dir, "db.dot"),
+		filepath.Join(dir, "registry.local_5000_team_app.dot"),
+	}
+	if !reflect.DeepEqual(written, expected) {
+		t.Fatalf("wrote %v, expected %v", written, expected)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("directory holds %v, expected %v", files, expected)
+	}
+
+	nodes := map[string][]string{
+		"base.dot":                         {`base -> "111111111111"`},
+		"db.dot":                           {`base -> "111111111111"`, `"111111111111" -> "444444444444"`},
+		"registry.local_5000_team_app.dot": {`base -> "111111111111"`, `"111111111111" -> "333333333333"`},
+	}
+	for name, edges := range nodes {
+		content, _ := ioutil.ReadFile(filepath.Join(dir, name))
+		if strings.Count(string(content), " -> ") != len(edges) {
+			t.Errorf("%s has unexpected edges:\n%s", name, content)
+		}
+		for _, edge := range edges {
+			if !strings.Contains(string(content), edge) {
+				t.Errorf("%s is missing '%s':\n%s", name, edge, content)
+			}
+		}
+	}
+}
+
+func Test_SplitByRepoCollisions(t *testing.T) {
+	json := `[{"Id":"1111111111111111","ParentId":"","RepoTags":["team/app:1"]},{"Id":"2222222222222222","ParentId":"","RepoTags":["team_app:1"]},{"Id":"3333333333333333","ParentId":"","RepoTags":["team_app_2:1"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	dir := t.TempDir()
+	written, err := splitByRepo(*im, dir, DotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(dir, "team_app.dot"),
+		filepath.Join(dir, "team_app_2.dot"),
+		filepath.Join(dir, "team_app_2_2.dot"),
+	}
+	if !reflect.DeepEqual(written, expected) {
+		t.Fatalf("wrote %v, expected %v", written, expected)
+	}
+	for index, id := range []string{"111111111111", "222222222222", "333333333333"} {
+		if content, _ := ioutil.ReadFile(expected[index]); !strings.Contains(string(content), id) {
+			t.Errorf("%s does not hold %s:\n%s", expected[index], id, content)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type ageBucket struct {
+	label string
+	limit time.Duration
+	count int
+	size  int64
+}
+
+// defaultAgeBuckets are the age histogram buckets used unless --age-buckets
+// is given.
+func defaultAgeBuckets() []ageBucket {
+	day := 24 * time.Hour
+	return []ageBucket{
+		{label: "<1d", limit: day},
+		{label: "<1w", limit: 7 * day},
+		{label: "<1m", limit: 30 * day},
+		{label: "<1y", limit: 365 * day},
+	}
+}
+
+// parseAgeBuckets parses comma separated, strictly increasing durations such
+// as 24h,168h,720h into histogram buckets.
+func parseAgeBuckets(spec string) ([]ageBucket, error) {
+	var buckets []ageBucket
+	for _, threshold := range strings.Split(spec, ",") {
+		threshold = strings.TrimSpace(threshold)
+		limit, err := time.ParseDuration(threshold)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid age bucket '%s': %s", threshold, err)
+		}
+		if len(buckets) > 0 && limit <= buckets[len(buckets)-1].limit {
+			return nil, fmt.Errorf("Age buckets must be strictly increasing, but %s follows %s.", threshold, buckets[len(buckets)-1].label[1:])
+		}
+		buckets = append(buckets, ageBucket{label: "<" + threshold, limit: limit})
+	}
+
+	return buckets, nil
+}
+
+// ageHistogram buckets images by how long ago they were created and lists
+// the count and total size per bucket.  Images older than the last bucket
+// are counted as older, and images without a creation time as unknown.
+func ageHistogram(images []Image, now time.Time, thresholds []ageBucket, sizeFormat string) string {
+	buckets := append(append([]ageBucket{}, thresholds...), ageBucket{label: "older"})
+	unknown := ageBucket{label: "unknown"}
+
+	for _, image := range images {
+		bucket := &unknown
+		if image.Created != 0 {
+			age := now.Sub(time.Unix(image.Created, 0))
+			bucket = &buckets[len(buckets)-1]
+			for index := range buckets[:len(buckets)-1] {
+				if age < buckets[index].limit {
+					bucket = &buckets[index]
+					break
+				}
+			}
+		}
+		bucket.count++
+		bucket.size += image.VirtualSize
+	}
+	buckets = append(buckets, unknown)
+
+	maxCount := 0
+	for _, bucket := range buckets {
+		if bucket.count > maxCount {
+			maxCount = bucket.count
+		}
+	}
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "AGE\tIMAGES\tSIZE")
+	for _, bucket := range buckets {
+		// scale the bars so the largest bucket is 20 wide, with no bars at
+		// all when there are no images
+		var bar string
+		if maxCount > 0 {
+			bar = strings.Repeat("█", bucket.count*20/maxCount)
+		}
+		if len(bar) == 0 && bucket.count > 0 {
+			bar = "█"
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\n", bucket.label, bucket.count, formatSize(bucket.size, sizeFormat), bar)
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
+
+// timeline groups images by the calendar day, in zone, they were created on,
+// oldest first, listing how many were created and the tags among them.
+// Images without a creation time are counted as unknown, last.
+func timeline(images []Image, zone *time.Location) string {
+	counts := make(map[string]int)
+	tags := make(map[string][]string)
+	for _, image := range images {
+		day := "unknown"
+		if image.Created != 0 {
+			day = time.Unix(image.Created, 0).In(zone).Format("2006-01-02")
+		}
+		counts[day]++
+		if isTagged(image) {
+			tags[day] = append(tags[day], image.RepoTags...)
+		}
+	}
+
+	var days []string
+	for day := range counts {
+		if day != "unknown" {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	if counts["unknown"] > 0 {
+		days = append(days, "unknown")
+	}
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DAY\tIMAGES\tTAGS")
+	for _, day := range days {
+		sort.Strings(tags[day])
+		fmt.Fprintf(writer, "%s\t%d\t%s\n", day, counts[day], strings.Join(tags[day], ", "))
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
+
+type baseGroup struct {
+	name   string
+	images int
+	size   int64
+}
+
+// groupByBase counts the images descending from each root image, along
+// with the space their layers add on top of it, most descendants first (or
+// most space first when sorted by lineage).
+func groupByBase(images []Image, sizeFormat string, sortBy string, idLength int) string {
+	byParent := collectChildren(&images)
+
+	var groups []baseGroup
+	for _, root := range collectRoots(&images) {
+		var group baseGroup
+		if isTagged(root) {
+			group.name = root.RepoTags[0]
+		} else {
+			group.name = truncateTo(root.Id, idLength)
+		}
+		sumDescendants(root, byParent, &group)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if sortBy == "lineage" && groups[i].size != groups[j].size {
+			return groups[i].size > groups[j].size
+		}
+		if groups[i].images != groups[j].images {
+			return groups[i].images > groups[j].images
+		}
+		if groups[i].size != groups[j].size {
+			return groups[i].size > groups[j].size
+		}
+		return groups[i].name < groups[j].name
+	})
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "BASE\tIMAGES\tSIZE")
+	for _, group := range groups {
+		fmt.Fprintf(writer, "%s\t%d\t%s\n", group.name, group.images, formatSize(group.size, sizeFormat))
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
+
+func sumDescendants(image Image, byParent map[string][]Image, group *baseGroup) {
+	for _, child := range byParent[image.Id] {
+		group.images++
+		group.size += child.Size
+		sumDescendants(child, byParent, group)
+	}
+}
+
+type imageStats struct {
+	ImageCount           int          `json:"imageCount"`
+	TaggedCount          int          `json:"taggedCount"`
+	UntaggedCount        int          `json:"untaggedCount"`
+	RepoCount            int          `json:"repoCount"`
+	TotalVirtualSize     int64        `json:"totalVirtualSize"`
+	TotalIncrementalSize int64        `json:"totalIncrementalSize"`
+	LargestImage         largestImage `json:"largestImage"`
+	AverageSize          int64        `json:"averageSize"`
+}
+
+type largestImage struct {
+	Id   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+// computeStats summarizes a set of images.  The largest image and the
+// average are by virtual size.
+func computeStats(images []Image) imageStats {
+	var stats imageStats
+	repos := make(map[string]bool)
+	for _, image := range images {
+		stats.ImageCount++
+		if isTagged(image) {
+			stats.TaggedCount++
+			for _, repotag := range image.RepoTags {
+				reponame, _ := splitRepoTag(repotag)
+				repos[reponame] = true
+			}
+		} else {
+			stats.UntaggedCount++
+		}
+		stats.TotalVirtualSize += image.VirtualSize
+		stats.TotalIncrementalSize += image.Size
+		if len(stats.LargestImage.Id) == 0 || image.VirtualSize > stats.LargestImage.Size {
+			stats.LargestImage = largestImage{Id: image.Id, Size: image.VirtualSize}
+		}
+	}
+	stats.RepoCount = len(repos)
+	if stats.ImageCount > 0 {
+		stats.AverageSize = stats.TotalVirtualSize / int64(stats.ImageCount)
+	}
+
+	return stats
+}
+
+// statsJSON renders the summary statistics as an indented JSON object.
+func statsJSON(images []Image, style jsonStyle) (string, error) {
+	encoded, err := json.Marshal(computeStats(images))
+	if err == nil {
+		encoded, err = style.apply(encoded)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var indented bytes.Buffer
+	json.Indent(&indented, encoded, "", "  ")
+	return indented.String() + "\n", nil
+}
+
+// summaryLine condenses the summary statistics into one line, totalling the
+// images' own sizes so shared layers count once.
+func summaryLine(images []Image, sizeFormat string, idLength int) string {
+	stats := computeStats(images)
+	return fmt.Sprintf("Images: %d Total Size: %s Largest: %s (%s)\n", stats.ImageCount, formatSize(stats.TotalIncrementalSize, sizeFormat),
+		truncateTo(stats.LargestImage.Id, idLength), formatSize(stats.LargestImage.Size, sizeFormat))
+}
+
+// countByPrefix groups tags by the part of the tag before the first
+// separator, or by the part of the repository path before it when the
+// separator is a slash, reporting how many tags and how much image size each
+// prefix accounts for, most tags first.  Tags without the separator are
+// grouped as "(no prefix)", and an image counts once towards a prefix's size.
+func countByPrefix(images []Image, separator string, sizeFormat string) string {
+	type prefixGroup struct {
+		prefix string
+		tags   int
+		size   int64
+	}
+
+	byPrefix := make(map[string]*prefixGroup)
+	counted := make(map[string]bool)
+	for _, image := range images {
+		if !isTagged(image) {
+			continue
+		}
+		for _, repotag := range image.RepoTags {
+			repo, tag := splitRepoTag(repotag)
+			name := tag
+			if strings.Contains(separator, "/") {
+				name = repo
+			}
+			prefix := "(no prefix)"
+			if index := strings.Index(name, separator); index >= 0 {
+				prefix = name[:index]
+			}
+			group, exists := byPrefix[prefix]
+			if !exists {
+				group = &prefixGroup{prefix: prefix}
+				byPrefix[prefix] = group
+			}
+			group.tags++
+			if !counted[prefix+" "+image.Id] {
+				counted[prefix+" "+image.Id] = true
+				group.size += image.VirtualSize
+			}
+		}
+	}
+
+	var groups []*prefixGroup
+	for _, group := range byPrefix {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].tags != groups[j].tags {
+			return groups[i].tags > groups[j].tags
+		}
+		return groups[i].prefix < groups[j].prefix
+	})
+
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PREFIX\tTAGS\tSIZE")
+	for _, group := range groups {
+		fmt.Fprintf(writer, "%s\t%d\t%s\n", group.prefix, group.tags, formatSize(group.size, sizeFormat))
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
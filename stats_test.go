@@ -0,0 +1,194 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AgeHistogram(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	hours := func(count int64) int64 {
+		return now.Unix() - count*3600
+	}
+
+	images := []Image{
+		{Id: "a", Created: hours(1), VirtualSize: 1000},
+		{Id: "b", Created: hours(23), VirtualSize: 2000},
+		{Id: "c", Created: hours(24 * 3), VirtualSize: 3000},
+		{Id: "d", Created: hours(24 * 20), VirtualSize: 4000},
+		{Id: "e", Created: hours(24 * 200), VirtualSize: 5000},
+		{Id: "f", Created: hours(24 * 400), VirtualSize: 6000},
+		{Id: "g", Created: hours(24 * 800), VirtualSize: 7000},
+		{Id: "h", Created: 0, VirtualSize: 8000},
+	}
+
+	result := ageHistogram(images, now, defaultAgeBuckets(), "si")
+	expected := `AGE      IMAGES  SIZE
+<1d      2       3.0 KB   ████████████████████
+<1w      1       3.0 KB   ██████████
+<1m      1       4.0 KB   ██████████
+<1y      1       5.0 KB   ██████████
+older    2       13.0 KB  ████████████████████
+unknown  1       8.0 KB   ██████████
+`
+	if result != expected {
+		t.Fatalf("age histogram was\n%s\nexpected\n%s", result, expected)
+	}
+
+	// no images, no bars
+	result = ageHistogram(nil, now, defaultAgeBuckets(), "si")
+	if strings.Count(result, "\n") != 7 || strings.Contains(result, "█") || !strings.Contains(result, "\nunknown  0       0.0 B") {
+		t.Fatalf("unexpected age histogram without images %q", result)
+	}
+}
+
+func Test_Timeline(t *testing.T) {
+	json := `[{"Id":"1111111111111111","RepoTags":["base:latest"],"Created":1772323200},{"Id":"2222222222222222","ParentId":"1111111111111111","RepoTags":["<none>:<none>"],"Created":1772488800},{"Id":"3333333333333333","ParentId":"2222222222222222","RepoTags":["app:1","app:latest"],"Created":1772495940},{"Id":"4444444444444444","RepoTags":["tool:2"],"Created":1772496060},{"Id":"5555555555555555","RepoTags":["old:1"]}]`
+	im, _ := parseImagesJSON([]byte(json))
+
+	expected := `DAY         IMAGES  TAGS
+2026-03-01  1       base:latest
+2026-03-02  2       app:1, app:latest
+2026-03-03  1       tool:2
+unknown     1       old:1
+`
+	if result := timeline(*im, time.UTC); result != expected {
+		t.Fatalf("timeline was\n%s\nexpected\n%s", result, expected)
+	}
+
+	// an hour east of UTC, the image from just before midnight moves a day on
+	zone := time.FixedZone("UTC+1", 3600)
+	if result := timeline(*im, zone); !strings.Contains(result, "2026-03-03  2       app:1, app:latest, tool:2\n") {
+		t.Fatalf("timeline did not group by the zone's days:\n%s", result)
+	}
+}
+
+func Test_AgeBuckets(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	hours := func(count int64) int64 {
+		return now.Unix() - count*3600
+	}
+
+	buckets, err := parseAgeBuckets("24h, 168h,720h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images := []Image{
+		{Id: "a", Created: hours(23), VirtualSize: 1000},
+		{Id: "b", Created: hours(24), VirtualSize: 2000},
+		{Id: "c", Created: hours(200), VirtualSize: 3000},
+		{Id: "d", Created: hours(720), VirtualSize: 4000},
+	}
+
+	result := ageHistogram(images, now, buckets, "si")
+	expected := `AGE      IMAGES  SIZE
+<24h     1       1.0 KB  ████████████████████
+<168h    1       2.0 KB  ████████████████████
+<720h    1       3.0 KB  ████████████████████
+older    1       4.0 KB  ████████████████████
+unknown  0       0.0 B   
+`
+	if result != expected {
+		t.Fatalf("age histogram was\n%s\nexpected\n%s", result, expected)
+	}
+
+	for _, invalid := range []string{"24h,24h", "168h,24h", "24h,soon", ""} {
+		if _, err := parseAgeBuckets(invalid); err == nil {
+			t.Errorf("age buckets '%s' did not cause an error", invalid)
+		}
+	}
+}
+
+func Test_GroupByBase(t *testing.T) {
+	images := []Image{
+		{Id: "alpine", RepoTags: []string{"alpine:3"}, Size: 5000},
+		{Id: "app", ParentId: "layer", RepoTags: []string{"app:latest"}, Size: 300},
+		{Id: "layer", ParentId: "alpine", RepoTags: []string{"<none>:<none>"}, Size: 200},
+		{Id: "tool", ParentId: "alpine", RepoTags: []string{"tool:1"}, Size: 100},
+		{Id: "ubuntu", RepoTags: []string{"ubuntu:22.04"}, Size: 70000},
+		{Id: "web", ParentId: "ubuntu", RepoTags: []string{"web:2"}, Size: 9000},
+		{Id: "scratch0000000000", Size: 10},
+	}
+
+	result := groupByBase(images, "si", "name", 12)
+	expected := `BASE          IMAGES  SIZE
+alpine:3      3       600.0 B
+ubuntu:22.04  1       9.0 KB
+scratch00000  0       0.0 B
+`
+	if result != expected {
+		t.Fatalf("groups were\n%s\nexpected\n%s", result, expected)
+	}
+
+	result = groupByBase(images, "si", "lineage", 12)
+	expected = `BASE          IMAGES  SIZE
+ubuntu:22.04  1       9.0 KB
+alpine:3      3       600.0 B
+scratch00000  0       0.0 B
+`
+	if result != expected {
+		t.Fatalf("groups by lineage were\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_CountByPrefix(t *testing.T) {
+	images := []Image{
+		{Id: "1", RepoTags: []string{"app:pr-1"}, VirtualSize: 100},
+		{Id: "2", RepoTags: []string{"app:pr-2", "app:stable"}, VirtualSize: 200},
+		{Id: "3", RepoTags: []string{"app:release-1"}, VirtualSize: 400},
+		{Id: "4", RepoTags: []string{"<none>:<none>"}, VirtualSize: 800},
+		{Id: "5", RepoTags: []string{"node-exporter:pr-3"}, VirtualSize: 50},
+	}
+
+	result := countByPrefix(images, "-", "si")
+	expected := `PREFIX       TAGS  SIZE
+pr           3     350.0 B
+(no prefix)  1     200.0 B
+release      1     400.0 B
+`
+	if result != expected {
+		t.Fatalf("prefix counts were\n%s\nexpected\n%s", result, expected)
+	}
+
+	images = []Image{
+		{Id: "1", RepoTags: []string{"team/app:1.0", "team/web:2.0"}, VirtualSize: 100},
+		{Id: "2", RepoTags: []string{"localhost:5000/team/app:1.0"}, VirtualSize: 200},
+		{Id: "3", RepoTags: []string{"ubuntu:22.04"}, VirtualSize: 400},
+	}
+	result = countByPrefix(images, "/", "si")
+	expected = `PREFIX          TAGS  SIZE
+team            2     100.0 B
+(no prefix)     1     400.0 B
+localhost:5000  1     200.0 B
+`
+	if result != expected {
+		t.Fatalf("prefix counts by path were\n%s\nexpected\n%s", result, expected)
+	}
+}
+
+func Test_StatsJSON(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+
+	result, err := statsJSON(*im, jsonStyle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "stats.golden.json", result)
+}
+
+func Test_SummaryOnly(t *testing.T) {
+	written, err := executeImages(t, ImagesCommand{Tree: true, SummaryOnly: true, SizeFormat: "si", TruncLength: 12}, treeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != "Images: 6 Total Size: 764.6 MB Largest: aaf8d4d1bcca (752.6 MB)\n" {
+		t.Fatalf("expected only the summary line, got '%s'", written)
+	}
+
+	written, _ = executeImages(t, ImagesCommand{Tree: true, SummaryOnly: true, SizeFormat: "si", TruncLength: 6}, treeJSON)
+	if written != "Images: 6 Total Size: 764.6 MB Largest: aaf8d4 (752.6 MB)\n" {
+		t.Fatalf("--trunc-length was ignored in the summary line '%s'", written)
+	}
+}
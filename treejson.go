@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type jsonNode struct {
+	Id       string     `json:"id,omitempty"`
+	Size     int64      `json:"size,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Group    string     `json:"group,omitempty"`
+	Children []jsonNode `json:"children,omitempty"`
+
+	// every image below the node, not just the direct children
+	ChildrenCount int `json:"childrenCount"`
+}
+
+// writeTreeJSON writes the tree as a single nested JSON document.  With
+// foldUntagged, the untagged children of each image are wrapped in one
+// synthetic group node, labeled with how many layers it holds.
+func writeTreeJSON(w io.Writer, roots []Image, byParent map[string][]Image, opts TreeOptions, foldUntagged bool) error {
+	nodes := imagesToJSONNodes(roots, byParent, opts, foldUntagged, false)
+	if nodes == nil {
+		nodes = []jsonNode{}
+	}
+
+	encoded, err := json.Marshal(nodes)
+	if err == nil {
+		encoded, err = opts.JSONStyle.apply(encoded)
+	}
+	if err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	json.Indent(&indented, encoded, "", "  ")
+	indented.WriteString("\n")
+	_, err = w.Write(indented.Bytes())
+	return err
+}
+
+func imagesToJSONNodes(images []Image, byParent map[string][]Image, opts TreeOptions, foldUntagged bool, fold bool) []jsonNode {
+	var nodes []jsonNode
+	group := -1
+	for _, image := range images {
+		node := jsonNode{Id: image.Id, Size: image.VirtualSize}
+		if opts.Incremental {
+			node.Size = image.Size
+		}
+		if isTagged(image) {
+			node.Tags = image.RepoTags
+		}
+		node.Children = imagesToJSONNodes(byParent[image.Id], byParent, opts, foldUntagged, foldUntagged)
+		node.ChildrenCount = countDescendants(node.Children)
+
+		if !fold || isTagged(image) {
+			nodes = append(nodes, node)
+			continue
+		}
+		if group < 0 {
+			group = len(nodes)
+			nodes = append(nodes, jsonNode{})
+		}
+		nodes[group].Children = append(nodes[group].Children, node)
+	}
+
+	if group >= 0 {
+		nodes[group].ChildrenCount = countDescendants(nodes[group].Children)
+		if count := len(nodes[group].Children); count == 1 {
+			nodes[group].Group = "(1 intermediate layer)"
+		} else {
+			nodes[group].Group = fmt.Sprintf("(%d intermediate layers)", count)
+		}
+	}
+
+	return nodes
+}
+
+// countDescendants counts the images in and below nodes, skipping the group
+// nodes that only wrap them.
+func countDescendants(nodes []jsonNode) int {
+	count := 0
+	for _, node := range nodes {
+		if len(node.Group) == 0 {
+			count++
+		}
+		count += node.ChildrenCount
+	}
+	return count
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func Test_TreeJSONFoldUntagged(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+
+	var buffer bytes.Buffer
+	if err := writeTreeJSON(&buffer, roots, byParent, TreeOptions{}, true); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []jsonNode
+	if err := json.Unmarshal(buffer.Bytes(), &nodes); err != nil {
+		t.Fatalf("output is not valid JSON: %s", buffer.String())
+	}
+
+	// the untagged root stays a root, its two untagged children are folded
+	if len(nodes) != 1 || truncate(nodes[0].Id) != "4c1208b690c6" || len(nodes[0].Children) != 1 {
+		t.Fatalf("unexpected roots in %s", buffer.String())
+	}
+	group := nodes[0].Children[0]
+	if group.Group != "(2 intermediate layers)" || len(group.Id) > 0 || len(group.Children) != 2 {
+		t.Fatalf("unexpected group node in %s", buffer.String())
+	}
+	if truncate(group.Children[0].Id) != "626147582d2a" || truncate(group.Children[1].Id) != "735f5db56261" {
+		t.Fatalf("group wraps the wrong children in %s", buffer.String())
+	}
+
+	// tagged images stay in place, their own untagged children fold again
+	base := group.Children[0].Children[0]
+	if truncate(base.Id) != "574c5faaf8d4" || len(base.Children) != 1 || base.Children[0].Group != "(1 intermediate layer)" {
+		t.Fatalf("unexpected nodes under the group in %s", buffer.String())
+	}
+	if truncate(base.Children[0].Children[0].Id) != "aaf8d4d1bcca" {
+		t.Fatalf("inner group wraps the wrong child in %s", buffer.String())
+	}
+
+	// without folding, children are nested directly
+	buffer.Reset()
+	writeTreeJSON(&buffer, roots, byParent, TreeOptions{}, false)
+	nodes = nil
+	json.Unmarshal(buffer.Bytes(), &nodes)
+	if len(nodes[0].Children) != 2 || len(nodes[0].Children[0].Group) > 0 {
+		t.Fatalf("unfolded tree has group nodes in %s", buffer.String())
+	}
+}
+
+func Test_TreeJSONChildrenCount(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	roots, byParent := prepareTree(im, nil, true)
+
+	counts := make(map[string]int)
+	var walk func(nodes []jsonNode)
+	walk = func(nodes []jsonNode) {
+		for _, node := range nodes {
+			counts[truncate(node.Id)+node.Group] = node.ChildrenCount
+			walk(node.Children)
+		}
+	}
+	walk(imagesToJSONNodes(roots, byParent, TreeOptions{}, true, false))
+
+	expected := map[string]int{
+		"4c1208b690c6":            5,
+		"(2 intermediate layers)": 5,
+		"626147582d2a":            2,
+		"574c5faaf8d4":            1,
+		"(1 intermediate layer)":  1,
+		"aaf8d4d1bcca":            0,
+		"735f5db56261":            1,
+		"c87be8e5e697":            0,
+	}
+	for id, count := range expected {
+		if counts[id] != count {
+			t.Errorf("%s has childrenCount %d, expected %d", id, counts[id], count)
+		}
+	}
+}
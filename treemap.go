@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	treemapWidth  = 1000.0
+	treemapHeight = 600.0
+)
+
+type treemapRect struct {
+	image               Image
+	x, y, width, height float64
+}
+
+// layoutTreemap lays the images out as a squarified treemap filling the given
+// area, each image's rectangle sized by its weight.  Images without weight
+// are left out.
+func layoutTreemap(images []Image, weight func(Image) int64, width, height float64) []treemapRect {
+	var items []Image
+	var total float64
+	for _, image := range images {
+		if weight(image) > 0 {
+			items = append(items, image)
+			total += float64(weight(image))
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if weight(items[i]) != weight(items[j]) {
+			return weight(items[i]) > weight(items[j])
+		}
+		return items[i].Id < items[j].Id
+	})
+
+	areas := make([]float64, len(items))
+	for index, image := range items {
+		areas[index] = float64(weight(image)) * width * height / total
+	}
+
+	var rects []treemapRect
+	x, y := 0.0, 0.0
+	start := 0
+	for end := 1; end <= len(items); end++ {
+		side := math.Min(width, height)
+		if end < len(items) && worstRatio(areas[start:end+1], side) <= worstRatio(areas[start:end], side) {
+			continue
+		}
+
+		// lay the row out along the shorter side of the remaining area
+		rowArea := 0.0
+		for _, area := range areas[start:end] {
+			rowArea += area
+		}
+		offset := 0.0
+		if width >= height {
+			rowWidth := rowArea / height
+			for index := start; index < end; index++ {
+				rect := treemapRect{image: items[index], x: x, y: y + offset, width: rowWidth, height: areas[index] / rowWidth}
+				offset += rect.height
+				rects = append(rects, rect)
+			}
+			x += rowWidth
+			width -= rowWidth
+		} else {
+			rowHeight := rowArea / width
+			for index := start; index < end; index++ {
+				rect := treemapRect{image: items[index], x: x + offset, y: y, width: areas[index] / rowHeight, height: rowHeight}
+				offset += rect.width
+				rects = append(rects, rect)
+			}
+			y += rowHeight
+			height -= rowHeight
+		}
+		start = end
+	}
+
+	return rects
+}
+
+// worstRatio is the largest aspect ratio among a row of areas laid out along
+// a side of the given length.
+func worstRatio(areas []float64, side float64) float64 {
+	sum, smallest, largest := 0.0, math.Inf(1), 0.0
+	for _, area := range areas {
+		sum += area
+		smallest = math.Min(smallest, area)
+		largest = math.Max(largest, area)
+	}
+
+	return math.Max(side*side*largest/(sum*sum), sum*sum/(side*side*smallest))
+}
+
+// imagesToTreemap renders the images as an SVG treemap, colored by repository.
+func imagesToTreemap(images []Image, incremental bool, sizeFormat string, colorScheme palette, idLength int) string {
+	weight := func(image Image) int64 {
+		if incremental {
+			return image.Size
+		}
+		return image.VirtualSize
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\">\n", treemapWidth, treemapHeight))
+	for _, rect := range layoutTreemap(images, weight, treemapWidth, treemapHeight) {
+		label := truncateTo(rect.image.Id, idLength)
+		fill := "#d9d9d9"
+		if isTagged(rect.image) {
+			label = strings.Join(rect.image.RepoTags, ", ")
+			reponame, _ := splitRepoTag(rect.image.RepoTags[0])
+			fill = colorScheme.repoColor(reponame)
+		}
+
+		buffer.WriteString(fmt.Sprintf(" <rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\" stroke=\"white\"><title>%s %s</title></rect>\n",
+			rect.x, rect.y, rect.width, rect.height, fill, escapeXML(label), formatSize(weight(rect.image), sizeFormat)))
+		if rect.width > 40 && rect.height > 14 {
+			buffer.WriteString(fmt.Sprintf(" <text x=\"%.2f\" y=\"%.2f\" font-size=\"12\">%s</text>\n", rect.x+4, rect.y+14, escapeXML(label)))
+		}
+	}
+	buffer.WriteString("</svg>\n")
+
+	return buffer.String()
+}
+
+func escapeXML(text string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(text))
+	return buffer.String()
+}
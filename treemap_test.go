@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Treemap(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	result := imagesToTreemap(*im, true, "si", palettes["default"], 12)
+
+	if !strings.HasPrefix(result, "<svg ") || !strings.HasSuffix(result, "</svg>\n") {
+		t.Fatalf("treemap is not an svg document: '%s'", result)
+	}
+
+	rects := regexp.MustCompile(`<rect x="([\d.]+)" y="([\d.]+)" width="([\d.]+)" height="([\d.]+)"`).FindAllStringSubmatch(result, -1)
+	if len(rects) != len(*im) {
+		t.Fatalf("expected %d rects, got %d", len(*im), len(rects))
+	}
+
+	var total int64
+	for _, image := range *im {
+		total += image.Size
+	}
+	for _, image := range *im {
+		// rects are ordered largest first, so look the image up by its title
+		match := regexp.MustCompile(`<rect x="[\d.]+" y="[\d.]+" width="([\d.]+)" height="([\d.]+)"[^>]*><title>[^<]*` + truncateOrTags(image)).FindStringSubmatch(result)
+		if match == nil {
+			t.Fatalf("no rect for image %s", truncate(image.Id))
+		}
+		width, _ := strconv.ParseFloat(match[1], 64)
+		height, _ := strconv.ParseFloat(match[2], 64)
+		expected := float64(image.Size) / float64(total) * treemapWidth * treemapHeight
+		if math.Abs(width*height-expected) > expected*0.01+2 {
+			t.Errorf("rect for %s has area %.0f, expected %.0f", truncate(image.Id), width*height, expected)
+		}
+	}
+
+	if !strings.Contains(result, "<title>foo:latest 2.0 MB</title>") {
+		t.Fatalf("tagged image not labelled with its tag: '%s'", result)
+	}
+}
+
+func truncateOrTags(image Image) string {
+	if isTagged(image) {
+		return regexp.QuoteMeta(strings.Join(image.RepoTags, ", "))
+	}
+	return truncate(image.Id)
+}
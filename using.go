@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/fsouza/go-dockerclient"
+
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type ContainersUsingCommand struct {
+	RunningOnly bool `long:"running-only" description:"Only list running containers."`
+	NoTruncate  bool `short:"n" long:"no-trunc" description:"Don't truncate the container IDs."`
+}
+
+var containersUsingCommand ContainersUsingCommand
+
+func (x *ContainersUsingCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Please name one image, e.g. containers-using <image>")
+	}
+
+	client, err := connect()
+	if err != nil {
+		return err
+	}
+
+	images, err := daemonImages(client, false, "containers-using")
+	if err != nil {
+		return err
+	}
+
+	image, err := findStartImage(args[0], &images)
+	if err != nil {
+		return err
+	}
+
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return newKindError(ErrNoDaemon, "Unable to list containers: %w", err)
+	}
+
+	fmt.Print(listContainersUsing(containersUsing(*image, containers, containersUsingCommand.RunningOnly), containersUsingCommand.NoTruncate))
+
+	return nil
+}
+
+// containersUsing picks the containers based on the image, matching their
+// image id rather than the name they were started with, which may have been
+// moved to another image since.
+func containersUsing(image Image, containers []docker.APIContainers, runningOnly bool) []docker.APIContainers {
+	var using []docker.APIContainers
+	for _, container := range containers {
+		if runningOnly && container.State != "running" {
+			continue
+		}
+		if container.ImageID == image.Id {
+			using = append(using, container)
+		}
+	}
+	return using
+}
+
+func listContainersUsing(containers []docker.APIContainers, noTruncate bool) string {
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CONTAINER ID\tNAME\tSTATUS\tCREATED")
+	for _, container := range containers {
+		id := container.ID
+		if !noTruncate {
+			id = truncate(id)
+		}
+		name := containerName(Container{Names: container.Names})
+		created := time.Unix(container.Created, 0).UTC().Format("2006-01-02 15:04:05")
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", id, name, strings.TrimSpace(container.Status), created)
+	}
+	writer.Flush()
+
+	return buffer.String()
+}
+
+func init() {
+	parser.AddCommand("containers-using",
+		"List the containers based on an image.",
+		"",
+		&containersUsingCommand)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/fsouza/go-dockerclient"
+
+	"testing"
+)
+
+func Test_ContainersUsing(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+	containers := []docker.APIContainers{
+		{ID: "aaaaaaaaaaaaaaaa", Image: "foo", ImageID: "c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470", Names: []string{"/web"}, State: "running", Status: "Up 2 hours", Created: 1486114144},
+		{ID: "bbbbbbbbbbbbbbbb", Image: "c87be8e5e697", ImageID: "c87be8e5e697c735f5db5626147582d2ae3f2088574c5faaf8d4d1bccab99470", Names: []string{"/worker"}, State: "exited", Status: "Exited (0) 3 days ago", Created: 1386114144},
+		{ID: "cccccccccccccccc", Image: "base:latest", ImageID: "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", Names: []string{"/db"}, State: "running", Status: "Up 5 minutes", Created: 1486114144},
+		// started from foo:latest before the tag moved to another image
+		{ID: "dddddddddddddddd", Image: "foo:latest", ImageID: "574c5faaf8d4d1bccab994626147582d2ae3735f5db5f2c87be8e5e697c08870", Names: []string{"/old"}, State: "running", Status: "Up 9 days", Created: 1386114144},
+	}
+
+	image, _ := findStartImage("foo:latest", im)
+	result := listContainersUsing(containersUsing(*image, containers, false), false)
+	expected := `CONTAINER ID  NAME    STATUS                 CREATED
+aaaaaaaaaaaa  web     Up 2 hours             2017-02-03 09:29:04
+bbbbbbbbbbbb  worker  Exited (0) 3 days ago  2013-12-03 23:42:24
+`
+	if result != expected {
+		t.Fatalf("containers were\n%s\nexpected\n%s", result, expected)
+	}
+
+	running := containersUsing(*image, containers, true)
+	if len(running) != 1 || running[0].ID != "aaaaaaaaaaaaaaaa" {
+		t.Fatalf("running containers were %v", running)
+	}
+}
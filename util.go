@@ -2,10 +2,17 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"path"
+	"syscall"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
+	"golang.org/x/term"
 )
 
 func connect() (*docker.Client, error) {
@@ -47,5 +54,147 @@ func connect() (*docker.Client, error) {
 			return nil, err
 		}
 	}
+
+	// only probe the daemon up front when asked to wait for it
+	if globalOptions.Retries > 0 {
+		if err := withRetries(globalOptions.Retries, globalOptions.RetryDelay, client.Ping); err != nil {
+			return nil, newKindError(ErrNoDaemon, "Unable to connect to %s: %w", endpoint, err)
+		}
+	}
+
+	// the version costs a round-trip, so it is only asked for when shown or
+	// when a flag depends on it (see probeDaemon)
+	daemon = daemonInfo{Endpoint: endpoint}
+	if globalOptions.Verbose {
+		if err := probeDaemon(client); err == nil {
+			fmt.Fprintf(os.Stderr, "Connected to Docker %s (API %s) at %s\n", daemon.Version, daemon.APIVersion, endpoint)
+		}
+	}
+	return client, nil
+}
+
+// sleep is swapped out in tests.
+var sleep = time.Sleep
+
+// withRetries calls try until it succeeds, retrying up to retries times and
+// doubling the delay after each failure.  The last error is returned when all
+// attempts fail.
+func withRetries(retries int, delay time.Duration, try func() error) error {
+	return withRetriesIf(retries, delay, func(error) bool { return true }, try)
+}
+
+// withRetriesIf is withRetries, giving up early on errors that retryable
+// rejects.
+func withRetriesIf(retries int, delay time.Duration, retryable func(error) bool, try func() error) error {
+	err := try()
+	for attempt := 0; attempt < retries && err != nil && retryable(err); attempt++ {
+		sleep(delay)
+		delay *= 2
+		err = try()
+	}
+	return err
+}
+
+// imageLister is the part of the Docker client that lists images.
+type imageLister interface {
+	ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error)
+}
+
+// minListRetries is how often a transient failure to list images is retried
+// when --retries asks for fewer.
+const minListRetries = 2
+
+// daemonClient is the part of the Docker client the images command uses.
+type daemonClient interface {
+	imageLister
+	historyClient
+	versionClient
+	ListContainers(opts docker.ListContainersOptions) ([]docker.APIContainers, error)
+}
+
+// connectDaemon connects the images command to the daemon; it is swapped out
+// in tests.
+var connectDaemon = func() (daemonClient, error) {
+	client, err := connect()
+	if err != nil {
+		return nil, err
+	}
 	return client, nil
 }
+
+// listImages fetches the images, retrying transient failures like withRetries.
+// The images API has neither pagination nor a way to split the listing into
+// chunks, so the whole set comes back from a single call and each retry starts
+// over.  Errors the daemon reports deliberately, such as a bad filter, are not
+// retried.
+func listImages(client imageLister, opts docker.ListImagesOptions, retries int, delay time.Duration) ([]docker.APIImages, error) {
+	if retries < minListRetries {
+		retries = minListRetries
+	}
+	var images []docker.APIImages
+	err := withRetriesIf(retries, delay, transientError, func() (err error) {
+		images, err = client.ListImages(opts)
+		return err
+	})
+	return images, err
+}
+
+// transientError tells whether an error from the daemon might go away when
+// the call is repeated: a dropped connection or a server-side failure.
+func transientError(err error) bool {
+	var apiError *docker.Error
+	if errors.As(err, &apiError) {
+		return apiError.Status >= 500
+	}
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// stdinImages parses the image JSON piped in on stdin, or returns nil when
+// stdin is a terminal and the images have to come from the daemon.
+func stdinImages() (*[]Image, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin stat: %w", err)
+	}
+	if (stat.Mode() & os.ModeCharDevice) != 0 {
+		return nil, nil
+	}
+
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("error reading all input: %w", err)
+	}
+	return parseImagesJSON(stdin)
+}
+
+// daemonImages lists all of the daemon's images, retrying as the global
+// options ask.  When dockviz runs in a container, a failure explains how to
+// give it the Docker socket for the named command.
+func daemonImages(client imageLister, digests bool, command string) ([]Image, error) {
+	listing := startProgress(progressWriter(os.Stderr, globalOptions.Quiet), "Listing images", 0)
+	clientImages, err := listImages(client, docker.ListImagesOptions{All: true, Digests: digests}, globalOptions.Retries, globalOptions.RetryDelay)
+	listing.done()
+	if err != nil {
+		if in_docker := os.Getenv("IN_DOCKER"); len(in_docker) > 0 {
+			return nil, newKindError(ErrNoDaemon, "Unable to access Docker socket (%w), please run like this:\n  docker run --rm -v /var/run/docker.sock:/var/run/docker.sock nate/dockviz %s <args>\nFor more help, run 'dockviz help'", err, command)
+		}
+		return nil, newKindError(ErrNoDaemon, "Unable to connect: %w\nFor help, run 'dockviz help'", err)
+	}
+
+	return fromAPIImages(clientImages), nil
+}
+
+// terminalWidth is the width of the terminal on stdout, or 0 when stdout is
+// not a terminal.
+func terminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	width, _, err := term.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+	return width
+}
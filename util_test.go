@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func Test_WithRetries(t *testing.T) {
+	var delays []time.Duration
+	original := sleep
+	sleep = func(delay time.Duration) { delays = append(delays, delay) }
+	defer func() { sleep = original }()
+
+	attempts := 0
+	flaky := func(failures int) func() error {
+		return func() error {
+			attempts++
+			if attempts <= failures {
+				return fmt.Errorf("attempt %d failed", attempts)
+			}
+			return nil
+		}
+	}
+
+	if err := withRetries(3, 100*time.Millisecond, flaky(2)); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("made %d attempts, expected 3", attempts)
+	}
+	if expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}; !reflect.DeepEqual(delays, expected) {
+		t.Fatalf("slept %v, expected %v", delays, expected)
+	}
+
+	attempts, delays = 0, nil
+	err := withRetries(2, time.Second, flaky(5))
+	if err == nil || err.Error() != "attempt 3 failed" || attempts != 3 {
+		t.Fatalf("expected the last error after 3 attempts, got %v after %d", err, attempts)
+	}
+
+	attempts, delays = 0, nil
+	if err := withRetries(0, time.Second, flaky(1)); err == nil || attempts != 1 || len(delays) != 0 {
+		t.Fatalf("zero retries should try once, got %v after %d attempts", err, attempts)
+	}
+}
+
+type flakyLister struct {
+	failures int
+	calls    int
+	err      error
+}
+
+func (l *flakyLister) ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error) {
+	l.calls++
+	if l.calls <= l.failures {
+		return nil, l.err
+	}
+	return []docker.APIImages{{ID: "1111"}, {ID: "2222"}, {ID: "3333"}}, nil
+}
+
+func Test_ListImagesRetries(t *testing.T) {
+	original := sleep
+	sleep = func(time.Duration) {}
+	defer func() { sleep = original }()
+
+	reset := fmt.Errorf("read: %w", syscall.ECONNRESET)
+
+	// transient failures are retried even without --retries
+	lister := &flakyLister{failures: 2, err: reset}
+	images, err := listImages(lister, docker.ListImagesOptions{All: true}, 0, time.Second)
+	if err != nil || len(images) != 3 || lister.calls != 3 {
+		t.Fatalf("expected all 3 images after 3 calls, got %v (%v) after %d", images, err, lister.calls)
+	}
+
+	lister = &flakyLister{failures: 4, err: reset}
+	if images, err := listImages(lister, docker.ListImagesOptions{All: true}, 3, time.Second); err == nil || images != nil || lister.calls != 4 {
+		t.Fatalf("expected the listing to fail without a partial result, got %v (%v) after %d", images, err, lister.calls)
+	}
+
+	for _, err := range []error{&docker.Error{Status: 500, Message: "server error"}, io.ErrUnexpectedEOF} {
+		lister = &flakyLister{failures: 1, err: err}
+		if _, err := listImages(lister, docker.ListImagesOptions{All: true}, 0, time.Second); err != nil || lister.calls != 2 {
+			t.Errorf("transient error was not retried: %v after %d calls", err, lister.calls)
+		}
+	}
+
+	// errors the daemon meant are returned straight away
+	for _, err := range []error{&docker.Error{Status: 400, Message: "invalid filter"}, errors.New("unexpected")} {
+		lister = &flakyLister{failures: 1, err: err}
+		if _, err := listImages(lister, docker.ListImagesOptions{All: true}, 3, time.Second); err == nil || lister.calls != 1 {
+			t.Errorf("permanent error was retried: %v after %d calls", err, lister.calls)
+		}
+	}
+}
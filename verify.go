@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// verifyImages compares saved images with those the daemon lists, by id: the
+// images only one side has, and the images whose sizes differ.  It returns an
+// error along with the report when anything differs.  Ids are shown with
+// idLength characters, or more when needed to tell the images of both sides
+// apart.
+func verifyImages(client imageLister, saved []Image, sizeFormat string, idLength int) (string, error) {
+	clientImages, err := listImages(client, docker.ListImagesOptions{All: true}, globalOptions.Retries, globalOptions.RetryDelay)
+	if err != nil {
+		return "", newKindError(ErrNoDaemon, "Unable to list images to verify against: %w", err)
+	}
+
+	listed := fromAPIImages(clientImages)
+	current := make(map[string]Image)
+	for _, image := range listed {
+		current[image.Id] = image
+	}
+	for _, image := range saved {
+		if _, exists := current[image.Id]; !exists {
+			listed = append(listed, image)
+		}
+	}
+	idLength = distinctLength(listed, idLength)
+	inSaved := make(map[string]bool)
+
+	var onlySaved, onlyDaemon, different []string
+	for _, image := range saved {
+		inSaved[image.Id] = true
+		other, exists := current[image.Id]
+		switch {
+		case !exists:
+			onlySaved = append(onlySaved, truncateTo(image.Id, idLength))
+		case other.VirtualSize != image.VirtualSize || other.Size != image.Size:
+			different = append(different, fmt.Sprintf("%s (%s / %s vs %s / %s)", truncateTo(image.Id, idLength),
+				formatSize(image.VirtualSize, sizeFormat), formatSize(image.Size, sizeFormat),
+				formatSize(other.VirtualSize, sizeFormat), formatSize(other.Size, sizeFormat)))
+		}
+	}
+	for id := range current {
+		if !inSaved[id] {
+			onlyDaemon = append(onlyDaemon, truncateTo(id, idLength))
+		}
+	}
+
+	var buffer bytes.Buffer
+	sections := []struct {
+		heading string
+		ids     []string
+	}{
+		{"Only in input:", onlySaved},
+		{"Only in daemon:", onlyDaemon},
+		{"Different sizes (virtual / own, input vs daemon):", different},
+	}
+	for _, section := range sections {
+		sort.Strings(section.ids)
+		buffer.WriteString(section.heading + "\n")
+		for _, id := range section.ids {
+			buffer.WriteString("  " + id + "\n")
+		}
+	}
+
+	if count := len(onlySaved) + len(onlyDaemon) + len(different); count > 0 {
+		return buffer.String(), fmt.Errorf("Found %d differences between the input and the daemon.", count)
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+type stubImageLister []docker.APIImages
+
+func (l stubImageLister) ListImages(opts docker.ListImagesOptions) ([]docker.APIImages, error) {
+	return l, nil
+}
+
+func Test_VerifyImages(t *testing.T) {
+	im, _ := parseImagesJSON([]byte(treeJSON))
+
+	listed := stubImageLister{}
+	for _, image := range *im {
+		listed = append(listed, docker.APIImages{ID: image.Id, ParentID: image.ParentId, VirtualSize: image.VirtualSize, Size: image.Size})
+	}
+
+	report, err := verifyImages(listed, *im, "si", 12)
+	if err != nil || report != "Only in input:\nOnly in daemon:\nDifferent sizes (virtual / own, input vs daemon):\n" {
+		t.Fatalf("matching images reported '%s' (%v)", report, err)
+	}
+
+	// the daemon lost one image, gained another and resized a third
+	listed = append(listed[1:], docker.APIImages{ID: "9999999999999999", VirtualSize: 100})
+	listed[0].VirtualSize += 1000000
+	report, err = verifyImages(listed, *im, "si", 12)
+	expected := `Only in input:
+  c87be8e5e697
+Only in daemon:
+  999999999999
+Different sizes (virtual / own, input vs daemon):
+  626147582d2a (682.6 MB / 20.0 MB vs 683.6 MB / 20.0 MB)
+`
+	if report != expected {
+		t.Fatalf("verify reported\n%s\nexpected\n%s", report, expected)
+	}
+	if err == nil || err.Error() != "Found 3 differences between the input and the daemon." {
+		t.Fatalf("expected an error for the differences, got %v", err)
+	}
+}